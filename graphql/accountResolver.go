@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/theshubhamy/microGo/services/account"
+)
+
+type accountResolver struct {
+	server *Server
+}
+
+// Addresses are only visible to the account's owner.
+func (r *accountResolver) Addresses(ctx context.Context, obj *Account) ([]*Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" || userID != obj.ID {
+		return nil, ErrUnauthorized
+	}
+
+	addressList, err := r.server.accountClient.ListAddresses(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Addresses failed", "err", err)
+		return nil, err
+	}
+
+	addresses := []*Address{}
+	for _, a := range addressList {
+		addresses = append(addresses, toAddress(&a))
+	}
+	return addresses, nil
+}
+
+func toAddress(a *account.Address) *Address {
+	return &Address{
+		ID:        a.ID,
+		Label:     a.Label,
+		Line1:     a.Line1,
+		Line2:     a.Line2,
+		Landmark:  a.Landmark,
+		City:      a.City,
+		State:     a.State,
+		Pincode:   a.Pincode,
+		Latitude:  a.Latitude,
+		Longitude: a.Longitude,
+		IsDefault: a.IsDefault,
+	}
+}
@@ -0,0 +1,69 @@
+package graphql
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/theshubhamy/microGo/services/catalog"
+)
+
+type categoryResolver struct {
+	server *Server
+}
+
+// Products lists the products in the category and its subcategories, with
+// their stock at storeID or across stores.
+func (r *categoryResolver) Products(ctx context.Context, obj *Category, pagination *PaginationInput, storeID *string) ([]*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	skip, take := uint64(0), uint64(0)
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+	productList, err := r.server.catalogClient.GetProducts(ctx, "", obj.ID, nil, skip, take)
+	if err != nil {
+		slog.ErrorContext(ctx, "Products failed", "err", err)
+		return nil, err
+	}
+
+	products := []*Product{}
+	for _, p := range *productList {
+		products = append(products, toProduct(&p))
+	}
+	store := ""
+	if storeID != nil {
+		store = *storeID
+	}
+	if err := r.server.fillStock(ctx, products, store); err != nil {
+		slog.ErrorContext(ctx, "Products failed", "err", err)
+		return nil, err
+	}
+	return products, nil
+}
+
+func toCategory(c *catalog.Category) *Category {
+	category := &Category{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    int(c.Position),
+		Children:    []*Category{},
+	}
+	if c.ParentID != "" {
+		parentID := c.ParentID
+		category.ParentID = &parentID
+	}
+	return category
+}
+
+func toCategoryTree(nodes []*catalog.CategoryNode) []*Category {
+	categories := []*Category{}
+	for _, n := range nodes {
+		category := toCategory(&n.Category)
+		category.Children = toCategoryTree(n.Children)
+		categories = append(categories, category)
+	}
+	return categories
+}
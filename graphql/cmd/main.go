@@ -2,45 +2,57 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-redis/redis/v8"
-	"github.com/kelseyhightower/envconfig"
 	"github.com/rs/cors"
 	"github.com/theshubhamy/microGo/graphql"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/account"
 )
 
-type config struct {
-	AccountURL string `envconfig:"ACCOUNT_SERVICE_URL"`
-	CatalogURL string `envconfig:"CATALOG_SERVICE_URL"`
-	OrderURL   string `envconfig:"ORDER_SERVICE_URL"`
-	RedisURL   string `envconfig:"REDIS_URL"`
-}
-
 func main() {
-	var cfg config
-	if err := envconfig.Process("", &cfg); err != nil {
-		log.Fatal(err)
+	cfg, err := graphql.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logging.Fatal("Failed to load config", "err", err)
+	}
+	logging.Setup("graphql", cfg.Logging)
+	flushTraces, err := tracing.Setup(context.Background(), "graphql", cfg.Options)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "err", err)
 	}
+	defer flushTraces()
 	opt, err := redis.ParseURL(cfg.RedisURL)
 	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+		logging.Fatal("Failed to parse Redis URL", "err", err)
 	}
-	account.LoadConfig()
 	redisClient := redis.NewClient(opt)
+	redisClient.AddHook(metrics.RedisHook{})
+	redisClient.AddHook(tracing.RedisHook{})
 	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Could not connect to Redis: %v", err)
+		logging.Fatal("Could not connect to Redis", "err", err)
 	}
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			slog.Error("Error closing Redis client", "err", err)
+		}
+	}()
 	// Your custom server that provides resolvers
 	customServer, err := graphql.NewGraphQLServer(cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL)
 	if err != nil {
-		log.Fatal("GraphQLServer error:", err)
+		logging.Fatal("Failed to connect to services", "err", err)
 	}
+	defer customServer.Close()
 
 	// Build the gqlgen executable schema
 	schema := customServer.ToExecutableSchema()
@@ -49,17 +61,45 @@ func main() {
 	srv := handler.New(schema)
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graphql.ErrorPresenter)
+	srv.Use(graphql.Metrics{})
+	srv.Use(graphql.Tracing{})
 
 	// Wrap with Auth middleware
+	verifier := account.NewJWKSVerifier(cfg.JWKSURL, cfg.JWKSRefresh)
 
-	http.Handle("/graphql", graphql.AuthMiddleware(redisClient)(graphql.InjectRequestMeta(srv)))
+	// Request IDs are assigned first so requests rejected by auth carry one too
+	http.Handle("/graphql", graphql.InjectRequestMeta(graphql.AuthMiddleware(cfg, redisClient, verifier)(srv)))
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/graphql"))
 
+	// Liveness only needs the process to answer; readiness needs every
+	// downstream dependency and fails as soon as shutdown starts
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	checks := customServer.HealthChecks()
+	checks["redis"] = func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}
+	http.Handle("/readyz", health.Handler(ctx, checks))
+
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{logging.RequestIDHeader},
 	}).Handler(http.DefaultServeMux)
 
-	log.Println("server running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", corsHandler))
+	slog.Info("Server running", "url", fmt.Sprintf("http://localhost:%d", cfg.Port))
+	go func() {
+		if err := metrics.Serve(ctx, cfg.MetricsPort, cfg.ShutdownTimeout); err != nil {
+			logging.Fatal("Metrics server failed", "err", err)
+		}
+	}()
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Port), Handler: corsHandler}
+	if err := lifecycle.ServeHTTP(ctx, httpServer, cfg.DrainDelay, cfg.ShutdownTimeout); err != nil {
+		logging.Fatal("Server failed", "err", err)
+	}
+	slog.Info("Server stopped")
 }
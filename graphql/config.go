@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/config"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+)
+
+// GatewayConfig is the gateway configuration; Config is taken by gqlgen.
+type GatewayConfig struct {
+	Port        int    `envconfig:"PORT" yaml:"port"`
+	MetricsPort int    `envconfig:"METRICS_PORT" yaml:"metrics_port"`
+	AccountURL  string `envconfig:"ACCOUNT_SERVICE_URL" yaml:"account_service_url"`
+	CatalogURL  string `envconfig:"CATALOG_SERVICE_URL" yaml:"catalog_service_url"`
+	OrderURL    string `envconfig:"ORDER_SERVICE_URL" yaml:"order_service_url"`
+	RedisURL    string `envconfig:"REDIS_URL" yaml:"redis_url"`
+	// Access tokens are verified against the account service's public keys
+	JWKSURL     string        `envconfig:"JWKS_URL" yaml:"jwks_url"`
+	JWKSRefresh time.Duration `envconfig:"JWKS_REFRESH" yaml:"jwks_refresh"`
+	// Reject tokens used from a different IP/user agent than the session
+	CheckFingerprint bool `envconfig:"CHECK_SESSION_FINGERPRINT" yaml:"check_session_fingerprint"`
+	// How long to keep serving after SIGTERM while reporting not ready, so
+	// load balancers stop sending new requests
+	DrainDelay time.Duration `envconfig:"DRAIN_DELAY" yaml:"drain_delay"`
+	// How long in-flight requests may run on after SIGTERM
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	tracing.Options `yaml:",inline"`
+	Logging         logging.Options `yaml:",inline"`
+}
+
+func DefaultConfig() GatewayConfig {
+	return GatewayConfig{
+		Port:            8080,
+		MetricsPort:     9090,
+		JWKSURL:         "http://account:8081/.well-known/jwks.json",
+		JWKSRefresh:     10 * time.Minute,
+		DrainDelay:      5 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// LoadConfig overlays the YAML file at path, if any, and the environment on
+// DefaultConfig. The gateway needs the address of every service and Redis,
+// where it checks sessions.
+func LoadConfig(path string) (GatewayConfig, error) {
+	cfg := DefaultConfig()
+	err := config.Load(path, &cfg)
+	return cfg, err
+}
+
+func (c *GatewayConfig) Validate() error {
+	err := config.Required(map[string]string{
+		"ACCOUNT_SERVICE_URL": c.AccountURL,
+		"CATALOG_SERVICE_URL": c.CatalogURL,
+		"ORDER_SERVICE_URL":   c.OrderURL,
+		"REDIS_URL":           c.RedisURL,
+		"JWKS_URL":            c.JWKSURL,
+	})
+	if err != nil {
+		return err
+	}
+	if c.JWKSRefresh <= 0 {
+		return errors.New("JWKS_REFRESH must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.DrainDelay < 0 {
+		return errors.New("DRAIN_DELAY must not be negative")
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	return c.Options.Validate()
+}
@@ -0,0 +1,38 @@
+package graphql
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+// hasRole implements @hasRole: the field resolves only when the caller's
+// access token carries at least one of roles.
+func hasRole(ctx context.Context, obj any, next graphql.Resolver, roles []Role) (any, error) {
+	callerRoles, _ := ctx.Value(RolesKey).([]string)
+	for _, role := range roles {
+		if slices.Contains(callerRoles, roleName(role)) {
+			return next(ctx)
+		}
+	}
+	return nil, errs.PermissionDenied("forbidden: missing required role")
+}
+
+// roleName maps a GraphQL Role to the name used in tokens and the database.
+func roleName(role Role) string {
+	return strings.ToLower(role.String())
+}
+
+func toRoles(names []string) []Role {
+	roles := []Role{}
+	for _, name := range names {
+		role := Role(strings.ToUpper(name))
+		if role.IsValid() {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"context"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrUnauthorized = errs.Unauthenticated("unauthorized: user ID not found")
+
+// ErrorPresenter sets extensions.code on errors carrying a gRPC status, such
+// as the domain errors returned by the services, e.g. NOT_FOUND. Permission
+// errors keep the FORBIDDEN code @hasRole has always used. Field violations
+// and retry hints in the status details are passed along as extensions.fields
+// and extensions.retryAfter (seconds).
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if gqlErr.Err == nil {
+		return gqlErr
+	}
+	st, ok := status.FromError(gqlErr.Err)
+	if !ok {
+		return gqlErr
+	}
+
+	gqlErr.Message = st.Message()
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]any{}
+	}
+	gqlErr.Extensions["code"] = codeName(st.Code())
+	if violations := errs.FieldViolations(st); len(violations) > 0 {
+		fields := []map[string]string{}
+		for _, v := range violations {
+			fields = append(fields, map[string]string{"field": v.Field, "message": v.Description})
+		}
+		gqlErr.Extensions["fields"] = fields
+	}
+	for _, detail := range st.Details() {
+		if retry, ok := detail.(*errdetails.RetryInfo); ok {
+			gqlErr.Extensions["retryAfter"] = int(math.Ceil(retry.RetryDelay.AsDuration().Seconds()))
+		}
+	}
+	return gqlErr
+}
+
+func codeName(code codes.Code) string {
+	if code == codes.PermissionDenied {
+		return "FORBIDDEN"
+	}
+	return errs.CodeName(code)
+}
@@ -1,9 +1,8 @@
 package graphql
 
 import (
-	"log"
-
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/theshubhamy/microGo/pkg/health"
 	"github.com/theshubhamy/microGo/services/account"
 	"github.com/theshubhamy/microGo/services/catalog"
 	"github.com/theshubhamy/microGo/services/order"
@@ -19,7 +18,6 @@ func NewGraphQLServer(accountUrl, catalogURL, orderURL string) (*Server, error)
 	// Connect to account service
 	accountClient, err := account.NewClient(accountUrl)
 	if err != nil {
-		log.Println("accountClienterror", err)
 		return nil, err
 	}
 
@@ -45,6 +43,34 @@ func NewGraphQLServer(accountUrl, catalogURL, orderURL string) (*Server, error)
 	}, nil
 }
 
+// Close closes the connections to the downstream services.
+func (s *Server) Close() {
+	s.accountClient.Close()
+	s.catalogClient.Close()
+	s.orderClient.Close()
+}
+
+// HealthChecks checks the downstream services the gateway resolves against.
+func (s *Server) HealthChecks() health.Checks {
+	return health.Checks{
+		"account": s.accountClient.Ping,
+		"catalog": s.catalogClient.Ping,
+		"order":   s.orderClient.Ping,
+	}
+}
+
+func (s *Server) Account() AccountResolver {
+	return &accountResolver{
+		server: s,
+	}
+}
+
+func (s *Server) Category() CategoryResolver {
+	return &categoryResolver{
+		server: s,
+	}
+}
+
 func (s *Server) Mutation() MutationResolver {
 	return &mutationResolver{
 		server: s,
@@ -60,5 +86,8 @@ func (s *Server) Query() QueryResolver {
 func (s *Server) ToExecutableSchema() graphql.ExecutableSchema {
 	return NewExecutableSchema(Config{
 		Resolvers: s,
+		Directives: DirectiveRoot{
+			HasRole: hasRole,
+		},
 	})
 }
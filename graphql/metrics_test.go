@@ -0,0 +1,33 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+func TestRootFieldName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "single field", query: `query Whatever { accounts { id } }`, want: "accounts"},
+		{name: "alias ignored", query: `query { mine: account { id } }`, want: "account"},
+		{name: "mutation", query: `mutation RandomName123 { createOrder(order: {}) { id } }`, want: "createOrder"},
+		{name: "several fields", query: `query { account { id } products { id } }`, want: "multiple"},
+		{name: "fragment spread", query: `query { ...F } fragment F on Query { account { id } }`, want: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.ParseQuery(&ast.Source{Input: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rootFieldName(doc.Operations[0].SelectionSet); got != tt.want {
+				t.Errorf("rootFieldName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/logging"
 	"github.com/theshubhamy/microGo/services/account"
 )
 
@@ -19,29 +22,50 @@ const (
 	ctxKeyIP        contextKey = "ip"
 	ctxKeyUserAgent contextKey = "user-agent"
 	UserIDKey       contextKey = "userID"
+	SessionIDKey    contextKey = "sessionID"
+	RolesKey        contextKey = "roles"
 )
 
+// publicOperations can be called without an access token.
+var publicOperations = map[string]struct{}{
+	"loginAccount":  {},
+	"createAccount": {},
+	"refreshToken":  {},
+	"requestOTP":    {},
+	"verifyOTP":     {},
+	"verifyEmail":   {},
+}
+
+// InjectRequestMeta records the client's IP and user agent for the resolvers
+// and tags the request with an ID, echoed in the response, that every log
+// line and downstream RPC carries. A well-formed X-Request-ID from the client
+// is kept so requests can be correlated with its own logs.
 func InjectRequestMeta(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.Header.Get("X-Forwarded-For")
-		if ip == "" {
-			ip = r.RemoteAddr
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(requestID) {
+			requestID = logging.NewRequestID()
 		}
-		userAgent := r.Header.Get("User-Agent")
+		w.Header().Set(logging.RequestIDHeader, requestID)
 
-		ctx := context.WithValue(r.Context(), ctxKeyIP, ip)
-		ctx = context.WithValue(ctx, ctxKeyUserAgent, userAgent)
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		ctx = context.WithValue(ctx, ctxKeyIP, clientIP(r))
+		ctx = context.WithValue(ctx, ctxKeyUserAgent, r.Header.Get("User-Agent"))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func AuthMiddleware(redisClient *redis.Client) func(http.Handler) http.Handler {
+// AuthMiddleware verifies the access token against the account service's
+// published keys and checks the Redis session it was issued for. With
+// CheckFingerprint set, the request must also come from the same IP and user
+// agent that created the session.
+func AuthMiddleware(cfg GatewayConfig, redisClient *redis.Client, verifier *account.JWKSVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			opName := extractOperationName(r)
 
-			if opName == "loginAccount" || opName == "createAccount" {
+			if _, ok := publicOperations[opName]; ok {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -53,25 +77,51 @@ func AuthMiddleware(redisClient *redis.Client) func(http.Handler) http.Handler {
 				return
 			}
 
-			claims, err := account.VerifyJWT(token)
+			claims, err := verifier.Verify(r.Context(), token)
 			if err != nil {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
 
-			sessionKey := fmt.Sprintf("user-sessions:%s", claims.UserID)
-			exists, err := redisClient.Exists(r.Context(), sessionKey).Result()
-			if err != nil || exists == 0 {
+			sessionKey := fmt.Sprintf("session:%s", claims.SessionID)
+			val, err := redisClient.Get(r.Context(), sessionKey).Result()
+			if err != nil {
+				http.Error(w, "Session not found", http.StatusUnauthorized)
+				return
+			}
+
+			var session account.SessionData
+			if err := json.Unmarshal([]byte(val), &session); err != nil || session.UserID != claims.UserID {
 				http.Error(w, "Session not found", http.StatusUnauthorized)
 				return
 			}
 
+			if cfg.CheckFingerprint && session.Fingerprint != account.GenerateFingerprint(clientIP(r), r.Header.Get("User-Agent")) {
+				http.Error(w, "Session fingerprint mismatch", http.StatusUnauthorized)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
+			ctx = context.WithValue(ctx, RolesKey, claims.Roles)
+			// Forward the token so services can enforce roles themselves
+			ctx = auth.WithToken(ctx, token)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func extractBearerToken(header string) string {
 	if strings.HasPrefix(header, "Bearer ") {
 		return strings.TrimPrefix(header, "Bearer ")
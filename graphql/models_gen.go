@@ -3,15 +3,34 @@
 package graphql
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
 type Account struct {
-	ID     string   `json:"id"`
-	Name   string   `json:"name"`
-	Email  string   `json:"email"`
-	Phone  string   `json:"phone"`
-	Orders []*Order `json:"orders"`
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Phone         string     `json:"phone"`
+	EmailVerified bool       `json:"emailVerified"`
+	PhoneVerified bool       `json:"phoneVerified"`
+	Roles         []Role     `json:"roles"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	Deleted       bool       `json:"deleted"`
+	Orders        []*Order   `json:"orders"`
+	Addresses     []*Address `json:"addresses"`
+}
+
+type AccountFilterInput struct {
+	Query         *string         `json:"query,omitempty"`
+	CreatedAfter  *time.Time      `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time      `json:"createdBefore,omitempty"`
+	EmailVerified *bool           `json:"emailVerified,omitempty"`
+	PhoneVerified *bool           `json:"phoneVerified,omitempty"`
+	Deletion      *DeletionStatus `json:"deletion,omitempty"`
 }
 
 type AccountInput struct {
@@ -21,6 +40,66 @@ type AccountInput struct {
 	Password string `json:"password"`
 }
 
+type AccountPage struct {
+	Accounts   []*Account `json:"accounts"`
+	NextCursor *string    `json:"nextCursor,omitempty"`
+}
+
+type Address struct {
+	ID        string  `json:"id"`
+	Label     string  `json:"label"`
+	Line1     string  `json:"line1"`
+	Line2     string  `json:"line2"`
+	Landmark  string  `json:"landmark"`
+	City      string  `json:"city"`
+	State     string  `json:"state"`
+	Pincode   string  `json:"pincode"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	IsDefault bool    `json:"isDefault"`
+}
+
+type AddressInput struct {
+	Label     string  `json:"label"`
+	Line1     string  `json:"line1"`
+	Line2     *string `json:"line2,omitempty"`
+	Landmark  *string `json:"landmark,omitempty"`
+	City      string  `json:"city"`
+	State     string  `json:"state"`
+	Pincode   string  `json:"pincode"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type AdjustStockInput struct {
+	StoreID        string             `json:"storeId"`
+	Items          []*StockDeltaInput `json:"items"`
+	Reason         StockReason        `json:"reason"`
+	Note           *string            `json:"note,omitempty"`
+	Reference      *string            `json:"reference,omitempty"`
+	IdempotencyKey *string            `json:"idempotencyKey,omitempty"`
+}
+
+type Category struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	ParentID    *string     `json:"parentId,omitempty"`
+	Position    int         `json:"position"`
+	Children    []*Category `json:"children"`
+	Products    []*Product  `json:"products"`
+}
+
+type CategoryInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+}
+
+type ChangePasswordInput struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
 type LoginInput struct {
 	Emailorphone string `json:"emailorphone"`
 	Password     string `json:"password"`
@@ -39,15 +118,20 @@ type Mutation struct {
 }
 
 type Order struct {
-	ID         string            `json:"id"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	TotalPrice float64           `json:"totalPrice"`
-	Products   []*OrderedProduct `json:"products"`
+	ID            string            `json:"id"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	TotalPrice    float64           `json:"totalPrice"`
+	PaymentMethod PaymentMethod     `json:"paymentMethod"`
+	StoreID       *string           `json:"storeId,omitempty"`
+	Products      []*OrderedProduct `json:"products"`
 }
 
 type OrderInput struct {
-	AccountID string               `json:"accountId"`
-	Products  []*OrderProductInput `json:"products"`
+	AccountID      string               `json:"accountId"`
+	Products       []*OrderProductInput `json:"products"`
+	PaymentMethod  *PaymentMethod       `json:"paymentMethod,omitempty"`
+	StoreID        *string              `json:"storeId,omitempty"`
+	IdempotencyKey string               `json:"idempotencyKey"`
 }
 
 type OrderProductInput struct {
@@ -69,17 +153,437 @@ type PaginationInput struct {
 }
 
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID                string       `json:"id"`
+	Name              string       `json:"name"`
+	Description       string       `json:"description"`
+	Price             float64      `json:"price"`
+	Mrp               float64      `json:"mrp"`
+	Brand             string       `json:"brand"`
+	Sku               string       `json:"sku"`
+	Barcode           string       `json:"barcode"`
+	UnitSize          *float64     `json:"unitSize,omitempty"`
+	UnitMeasure       *UnitMeasure `json:"unitMeasure,omitempty"`
+	CategoryIds       []string     `json:"categoryIds"`
+	ImageUrls         []string     `json:"imageUrls"`
+	Tags              []string     `json:"tags"`
+	Active            bool         `json:"active"`
+	InStock           bool         `json:"inStock"`
+	AvailableQuantity int          `json:"availableQuantity"`
 }
 
 type ProductInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       float64      `json:"price"`
+	Mrp         *float64     `json:"mrp,omitempty"`
+	Brand       *string      `json:"brand,omitempty"`
+	Sku         *string      `json:"sku,omitempty"`
+	Barcode     *string      `json:"barcode,omitempty"`
+	UnitSize    *float64     `json:"unitSize,omitempty"`
+	UnitMeasure *UnitMeasure `json:"unitMeasure,omitempty"`
+	CategoryIds []string     `json:"categoryIds,omitempty"`
+	ImageUrls   []string     `json:"imageUrls,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
 }
 
 type Query struct {
 }
+
+type Session struct {
+	ID        string    `json:"id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	Current   bool      `json:"current"`
+}
+
+type Stock struct {
+	ProductID string     `json:"productId"`
+	StoreID   string     `json:"storeId"`
+	Quantity  int        `json:"quantity"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type StockDeltaInput struct {
+	ProductID string `json:"productId"`
+	Delta     int    `json:"delta"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type UpdateAccountInput struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Phone *string `json:"phone,omitempty"`
+}
+
+type VerifyOTPInput struct {
+	Identifier string `json:"identifier"`
+	Code       string `json:"code"`
+}
+
+type Wallet struct {
+	ID       string  `json:"id"`
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+type WalletTransaction struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DeletionStatus string
+
+const (
+	DeletionStatusActive  DeletionStatus = "ACTIVE"
+	DeletionStatusDeleted DeletionStatus = "DELETED"
+	DeletionStatusAny     DeletionStatus = "ANY"
+)
+
+var AllDeletionStatus = []DeletionStatus{
+	DeletionStatusActive,
+	DeletionStatusDeleted,
+	DeletionStatusAny,
+}
+
+func (e DeletionStatus) IsValid() bool {
+	switch e {
+	case DeletionStatusActive, DeletionStatusDeleted, DeletionStatusAny:
+		return true
+	}
+	return false
+}
+
+func (e DeletionStatus) String() string {
+	return string(e)
+}
+
+func (e *DeletionStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DeletionStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DeletionStatus", str)
+	}
+	return nil
+}
+
+func (e DeletionStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DeletionStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DeletionStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCashOnDelivery PaymentMethod = "CASH_ON_DELIVERY"
+	PaymentMethodWallet         PaymentMethod = "WALLET"
+)
+
+var AllPaymentMethod = []PaymentMethod{
+	PaymentMethodCashOnDelivery,
+	PaymentMethodWallet,
+}
+
+func (e PaymentMethod) IsValid() bool {
+	switch e {
+	case PaymentMethodCashOnDelivery, PaymentMethodWallet:
+		return true
+	}
+	return false
+}
+
+func (e PaymentMethod) String() string {
+	return string(e)
+}
+
+func (e *PaymentMethod) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PaymentMethod(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PaymentMethod", str)
+	}
+	return nil
+}
+
+func (e PaymentMethod) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PaymentMethod) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PaymentMethod) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleCustomer       Role = "CUSTOMER"
+	RoleAdmin          Role = "ADMIN"
+	RoleCatalogManager Role = "CATALOG_MANAGER"
+	RoleSupport        Role = "SUPPORT"
+)
+
+var AllRole = []Role{
+	RoleCustomer,
+	RoleAdmin,
+	RoleCatalogManager,
+	RoleSupport,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleCustomer, RoleAdmin, RoleCatalogManager, RoleSupport:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type StockReason string
+
+const (
+	StockReasonRestock    StockReason = "RESTOCK"
+	StockReasonSale       StockReason = "SALE"
+	StockReasonReturn     StockReason = "RETURN"
+	StockReasonDamage     StockReason = "DAMAGE"
+	StockReasonCorrection StockReason = "CORRECTION"
+)
+
+var AllStockReason = []StockReason{
+	StockReasonRestock,
+	StockReasonSale,
+	StockReasonReturn,
+	StockReasonDamage,
+	StockReasonCorrection,
+}
+
+func (e StockReason) IsValid() bool {
+	switch e {
+	case StockReasonRestock, StockReasonSale, StockReasonReturn, StockReasonDamage, StockReasonCorrection:
+		return true
+	}
+	return false
+}
+
+func (e StockReason) String() string {
+	return string(e)
+}
+
+func (e *StockReason) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = StockReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid StockReason", str)
+	}
+	return nil
+}
+
+func (e StockReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *StockReason) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e StockReason) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type UnitMeasure string
+
+const (
+	UnitMeasureG  UnitMeasure = "G"
+	UnitMeasureKg UnitMeasure = "KG"
+	UnitMeasureMl UnitMeasure = "ML"
+	UnitMeasureL  UnitMeasure = "L"
+	UnitMeasurePc UnitMeasure = "PC"
+)
+
+var AllUnitMeasure = []UnitMeasure{
+	UnitMeasureG,
+	UnitMeasureKg,
+	UnitMeasureMl,
+	UnitMeasureL,
+	UnitMeasurePc,
+}
+
+func (e UnitMeasure) IsValid() bool {
+	switch e {
+	case UnitMeasureG, UnitMeasureKg, UnitMeasureMl, UnitMeasureL, UnitMeasurePc:
+		return true
+	}
+	return false
+}
+
+func (e UnitMeasure) String() string {
+	return string(e)
+}
+
+func (e *UnitMeasure) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UnitMeasure(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UnitMeasure", str)
+	}
+	return nil
+}
+
+func (e UnitMeasure) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UnitMeasure) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UnitMeasure) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type VerificationChannel string
+
+const (
+	VerificationChannelEmail VerificationChannel = "EMAIL"
+	VerificationChannelPhone VerificationChannel = "PHONE"
+)
+
+var AllVerificationChannel = []VerificationChannel{
+	VerificationChannelEmail,
+	VerificationChannelPhone,
+}
+
+func (e VerificationChannel) IsValid() bool {
+	switch e {
+	case VerificationChannelEmail, VerificationChannelPhone:
+		return true
+	}
+	return false
+}
+
+func (e VerificationChannel) String() string {
+	return string(e)
+}
+
+func (e *VerificationChannel) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VerificationChannel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VerificationChannel", str)
+	}
+	return nil
+}
+
+func (e VerificationChannel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *VerificationChannel) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e VerificationChannel) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -3,13 +3,17 @@ package graphql
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/services/account"
+	"github.com/theshubhamy/microGo/services/catalog"
 	"github.com/theshubhamy/microGo/services/order"
 )
 
-var ErrInvalidParameter = errors.New("invalid parameter")
+var ErrInvalidParameter = errs.InvalidArgument("quantity", "invalid parameter")
 
 type mutationResolver struct {
 	server *Server
@@ -21,7 +25,7 @@ func (r *mutationResolver) CreateAccount(ctx context.Context, in AccountInput) (
 
 	a, err := r.server.accountClient.PostAccount(ctx, in.Name, in.Email, in.Phone, in.Password)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CreateAccount failed", "err", err)
 		return nil, err
 	}
 
@@ -47,7 +51,7 @@ func (r *mutationResolver) LoginAccount(ctx context.Context, in LoginInput) (*Lo
 
 	acc, accessToken, refreshToken, err := r.server.accountClient.LoginAccount(ctx, in.Emailorphone, in.Password, ip, userAgent)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "LoginAccount failed", "err", err)
 		return nil, err
 	}
 
@@ -61,24 +65,426 @@ func (r *mutationResolver) LoginAccount(ctx context.Context, in LoginInput) (*Lo
 	}, nil
 }
 
-func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput) (*Product, error) {
+func (r *mutationResolver) UpdateAccount(ctx context.Context, in UpdateAccountInput) (*Account, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
 
-	p, err := r.server.catalogClient.PostProduct(ctx, in.Name, in.Description, in.Price)
+	var name, email, phone string
+	if in.Name != nil {
+		name = *in.Name
+	}
+	if in.Email != nil {
+		email = *in.Email
+	}
+	if in.Phone != nil {
+		phone = *in.Phone
+	}
+	a, err := r.server.accountClient.UpdateAccount(ctx, userID, name, email, phone)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "UpdateAccount failed", "err", err)
 		return nil, err
 	}
 
-	return &Product{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
+	return &Account{
+		ID:            a.ID,
+		Name:          a.Name,
+		Email:         a.Email,
+		Phone:         a.Phone,
+		EmailVerified: a.EmailVerified,
+		PhoneVerified: a.PhoneVerified,
+		Roles:         toRoles(a.Roles),
 	}, nil
 }
 
+func (r *mutationResolver) ChangePassword(ctx context.Context, in ChangePasswordInput) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.ChangePassword(ctx, userID, in.OldPassword, in.NewPassword); err != nil {
+		slog.ErrorContext(ctx, "ChangePassword failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) DeleteAccount(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.DeleteAccount(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "DeleteAccount failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) SendVerification(ctx context.Context, channel VerificationChannel) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.SendVerification(ctx, userID, strings.ToLower(channel.String())); err != nil {
+		slog.ErrorContext(ctx, "SendVerification failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) VerifyEmail(ctx context.Context, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.server.accountClient.VerifyEmail(ctx, token); err != nil {
+		slog.ErrorContext(ctx, "VerifyEmail failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) VerifyPhone(ctx context.Context, code string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.VerifyPhone(ctx, userID, code); err != nil {
+		slog.ErrorContext(ctx, "VerifyPhone failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) RequestOtp(ctx context.Context, identifier string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.server.accountClient.RequestOTP(ctx, identifier); err != nil {
+		slog.ErrorContext(ctx, "RequestOtp failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) VerifyOtp(ctx context.Context, in VerifyOTPInput) (*LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	ip, ok := ctx.Value(ctxKeyIP).(string)
+	if !ok {
+		return nil, errors.New("missing IP from context")
+	}
+	userAgent, ok := ctx.Value(ctxKeyUserAgent).(string)
+	if !ok {
+		return nil, errors.New("missing user agent from context")
+	}
+
+	acc, accessToken, refreshToken, err := r.server.accountClient.VerifyOTP(ctx, in.Identifier, in.Code, ip, userAgent)
+	if err != nil {
+		slog.ErrorContext(ctx, "VerifyOtp failed", "err", err)
+		return nil, err
+	}
+
+	return &LoginResponse{
+		ID:           acc.ID,
+		Name:         acc.Name,
+		Email:        acc.Email,
+		Phone:        acc.Phone,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	accessToken, newRefreshToken, err := r.server.accountClient.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "RefreshToken failed", "err", err)
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+// Logout revokes the given session, or the current one when sessionID is nil.
+func (r *mutationResolver) Logout(ctx context.Context, sessionID *string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+	sid, _ := ctx.Value(SessionIDKey).(string)
+	if sessionID != nil {
+		sid = *sessionID
+	}
+
+	if err := r.server.accountClient.Logout(ctx, userID, sid); err != nil {
+		slog.ErrorContext(ctx, "Logout failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) LogoutAll(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.LogoutAll(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "LogoutAll failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) CreateAddress(ctx context.Context, in AddressInput) (*Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	a, err := r.server.accountClient.CreateAddress(ctx, in.toAccountAddress(userID, ""))
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAddress failed", "err", err)
+		return nil, err
+	}
+	return toAddress(a), nil
+}
+
+func (r *mutationResolver) UpdateAddress(ctx context.Context, id string, in AddressInput) (*Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	a, err := r.server.accountClient.UpdateAddress(ctx, in.toAccountAddress(userID, id))
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateAddress failed", "err", err)
+		return nil, err
+	}
+	return toAddress(a), nil
+}
+
+func (r *mutationResolver) DeleteAddress(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return false, ErrUnauthorized
+	}
+
+	if err := r.server.accountClient.DeleteAddress(ctx, userID, id); err != nil {
+		slog.ErrorContext(ctx, "DeleteAddress failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) SetDefaultAddress(ctx context.Context, id string) (*Address, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	a, err := r.server.accountClient.SetDefaultAddress(ctx, userID, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "SetDefaultAddress failed", "err", err)
+		return nil, err
+	}
+	return toAddress(a), nil
+}
+
+func (in AddressInput) toAccountAddress(accountID, addressID string) account.Address {
+	a := account.Address{
+		ID:        addressID,
+		AccountID: accountID,
+		Label:     in.Label,
+		Line1:     in.Line1,
+		City:      in.City,
+		State:     in.State,
+		Pincode:   in.Pincode,
+		Latitude:  in.Latitude,
+		Longitude: in.Longitude,
+	}
+	if in.Line2 != nil {
+		a.Line2 = *in.Line2
+	}
+	if in.Landmark != nil {
+		a.Landmark = *in.Landmark
+	}
+	return a
+}
+
+func (r *mutationResolver) SetAccountRoles(ctx context.Context, accountID string, roles []Role) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, roleName(role))
+	}
+	if err := r.server.accountClient.SetAccountRoles(ctx, accountID, names); err != nil {
+		slog.ErrorContext(ctx, "SetAccountRoles failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	p, err := r.server.catalogClient.PostProduct(ctx, in.toCatalogProduct())
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateProduct failed", "err", err)
+		return nil, err
+	}
+
+	return toProduct(p), nil
+}
+
+func (r *mutationResolver) UpdateProduct(ctx context.Context, id string, categoryIds []string, active bool) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	p, err := r.server.catalogClient.UpdateProduct(ctx, id, categoryIds, active)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateProduct failed", "err", err)
+		return nil, err
+	}
+	return toProduct(p), nil
+}
+
+func (r *mutationResolver) CreateCategory(ctx context.Context, in CategoryInput, parentID *string, position *int) (*Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	category := in.toCatalogCategory("")
+	if parentID != nil {
+		category.ParentID = *parentID
+	}
+	if position != nil {
+		category.Position = int32(*position)
+	}
+	c, err := r.server.catalogClient.CreateCategory(ctx, category)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateCategory failed", "err", err)
+		return nil, err
+	}
+	return toCategory(c), nil
+}
+
+func (r *mutationResolver) UpdateCategory(ctx context.Context, id string, in CategoryInput) (*Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	c, err := r.server.catalogClient.UpdateCategory(ctx, in.toCatalogCategory(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateCategory failed", "err", err)
+		return nil, err
+	}
+	return toCategory(c), nil
+}
+
+func (r *mutationResolver) MoveCategory(ctx context.Context, id string, parentID *string, position *int) (*Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	parent, pos := "", int32(0)
+	if parentID != nil {
+		parent = *parentID
+	}
+	if position != nil {
+		pos = int32(*position)
+	}
+	c, err := r.server.catalogClient.MoveCategory(ctx, id, parent, pos)
+	if err != nil {
+		slog.ErrorContext(ctx, "MoveCategory failed", "err", err)
+		return nil, err
+	}
+	return toCategory(c), nil
+}
+
+func (r *mutationResolver) DeleteCategory(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.server.catalogClient.DeleteCategory(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "DeleteCategory failed", "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (in CategoryInput) toCatalogCategory(id string) catalog.Category {
+	c := catalog.Category{ID: id, Name: in.Name}
+	if in.Description != nil {
+		c.Description = *in.Description
+	}
+	return c
+}
+
+func (in ProductInput) toCatalogProduct() catalog.Product {
+	p := catalog.Product{
+		Name:        in.Name,
+		Description: in.Description,
+		Price:       in.Price,
+		CategoryIDs: in.CategoryIds,
+		ImageURLs:   in.ImageUrls,
+		Tags:        in.Tags,
+		Active:      in.Active == nil || *in.Active,
+	}
+	if in.Mrp != nil {
+		p.MRP = *in.Mrp
+	}
+	if in.Brand != nil {
+		p.Brand = *in.Brand
+	}
+	if in.Sku != nil {
+		p.SKU = *in.Sku
+	}
+	if in.Barcode != nil {
+		p.Barcode = *in.Barcode
+	}
+	if in.UnitSize != nil {
+		p.UnitSize = *in.UnitSize
+	}
+	if in.UnitMeasure != nil {
+		p.UnitMeasure = strings.ToLower(string(*in.UnitMeasure))
+	}
+	return p
+}
+
 func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
@@ -95,17 +501,63 @@ func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Ord
 	}
 	userID, ok := ctx.Value(UserIDKey).(string)
 	if !ok || userID == "" {
-		return nil, errors.New("unauthorized: user ID not found")
+		return nil, ErrUnauthorized
+	}
+	paymentMethod := order.PaymentCashOnDelivery
+	if in.PaymentMethod != nil && *in.PaymentMethod == PaymentMethodWallet {
+		paymentMethod = order.PaymentWallet
 	}
-	o, err := r.server.orderClient.PostOrder(ctx, userID, products)
+	storeID := ""
+	if in.StoreID != nil {
+		storeID = *in.StoreID
+	}
+	o, err := r.server.orderClient.PostOrder(ctx, userID, products, paymentMethod, storeID, in.IdempotencyKey)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CreateOrder failed", "err", err)
 		return nil, err
 	}
 
-	return &Order{
-		ID:         o.ID,
-		CreatedAt:  o.CreatedAt,
-		TotalPrice: o.TotalPrice,
-	}, nil
+	created := &Order{
+		ID:            o.ID,
+		CreatedAt:     o.CreatedAt,
+		TotalPrice:    o.TotalPrice,
+		PaymentMethod: toPaymentMethod(o.PaymentMethod),
+	}
+	if o.StoreID != "" {
+		created.StoreID = &o.StoreID
+	}
+	return created, nil
+}
+
+func (r *mutationResolver) AdjustStock(ctx context.Context, in AdjustStockInput) ([]*Stock, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	items := []catalog.StockDelta{}
+	for _, item := range in.Items {
+		items = append(items, catalog.StockDelta{ProductID: item.ProductID, Delta: int32(item.Delta)})
+	}
+	note, reference, idempotencyKey := "", "", ""
+	if in.Note != nil {
+		note = *in.Note
+	}
+	if in.Reference != nil {
+		reference = *in.Reference
+	}
+	if in.IdempotencyKey != nil {
+		idempotencyKey = *in.IdempotencyKey
+	}
+	stock, err := r.server.catalogClient.AdjustStock(ctx, in.StoreID, items, strings.ToLower(string(in.Reason)), note, reference, idempotencyKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "AdjustStock failed", "err", err)
+		return nil, err
+	}
+	return toStock(stock), nil
+}
+
+func toPaymentMethod(method string) PaymentMethod {
+	if method == order.PaymentWallet {
+		return PaymentMethodWallet
+	}
+	return PaymentMethodCashOnDelivery
 }
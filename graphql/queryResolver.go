@@ -2,32 +2,132 @@ package graphql
 
 import (
 	"context"
-	"errors"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
+
+	"github.com/theshubhamy/microGo/services/account"
+	"github.com/theshubhamy/microGo/services/catalog"
 )
 
 type queryResolver struct {
 	server *Server
 }
 
-func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInput, query *string, id *string) ([]*Product, error) {
+func (r *queryResolver) Me(ctx context.Context) (*Account, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	a, err := r.server.accountClient.GetAccount(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Me failed", "err", err)
+		return nil, err
+	}
+
+	return &Account{
+		ID:            a.ID,
+		Name:          a.Name,
+		Email:         a.Email,
+		Phone:         a.Phone,
+		EmailVerified: a.EmailVerified,
+		PhoneVerified: a.PhoneVerified,
+		Roles:         toRoles(a.Roles),
+		CreatedAt:     &a.CreatedAt,
+	}, nil
+}
+
+func (r *queryResolver) Accounts(ctx context.Context, filter *AccountFilterInput, first *int, after *string) (*AccountPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	take := uint64(0)
+	if first != nil && *first > 0 {
+		take = uint64(*first)
+	}
+	cursor := ""
+	if after != nil {
+		cursor = *after
+	}
+
+	accountList, next, err := r.server.accountClient.GetAccounts(ctx, filter.toAccountFilter(), cursor, 0, take)
+	if err != nil {
+		slog.ErrorContext(ctx, "Accounts failed", "err", err)
+		return nil, err
+	}
+
+	page := &AccountPage{Accounts: []*Account{}}
+	for _, a := range accountList {
+		page.Accounts = append(page.Accounts, &Account{
+			ID:            a.ID,
+			Name:          a.Name,
+			Email:         a.Email,
+			Phone:         a.Phone,
+			EmailVerified: a.EmailVerified,
+			PhoneVerified: a.PhoneVerified,
+			Roles:         toRoles(a.Roles),
+			CreatedAt:     &a.CreatedAt,
+			Deleted:       a.Deleted,
+		})
+	}
+	if next != "" {
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+func (f *AccountFilterInput) toAccountFilter() account.AccountFilter {
+	filter := account.AccountFilter{}
+	if f == nil {
+		return filter
+	}
+	if f.Query != nil {
+		filter.Query = *f.Query
+	}
+	if f.CreatedAfter != nil {
+		filter.CreatedAfter = *f.CreatedAfter
+	}
+	if f.CreatedBefore != nil {
+		filter.CreatedBefore = *f.CreatedBefore
+	}
+	filter.EmailVerified = f.EmailVerified
+	filter.PhoneVerified = f.PhoneVerified
+	if f.Deletion != nil {
+		switch *f.Deletion {
+		case DeletionStatusDeleted:
+			filter.Deletion = account.DeletionDeleted
+		case DeletionStatusAny:
+			filter.Deletion = account.DeletionAny
+		}
+	}
+	return filter
+}
+
+func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInput, query *string, id *string, categoryID *string, storeID *string) ([]*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	store := ""
+	if storeID != nil {
+		store = *storeID
+	}
 
 	// Get single
 	if id != nil {
-		r, err := r.server.catalogClient.GetProduct(ctx, *id)
+		p, err := r.server.catalogClient.GetProduct(ctx, *id)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "Products failed", "err", err)
 			return nil, err
 		}
-		return []*Product{{
-			ID:          r.ID,
-			Name:        r.Name,
-			Description: r.Description,
-			Price:       r.Price,
-		}}, nil
+		products := []*Product{toProduct(p)}
+		if err := r.server.fillStock(ctx, products, store); err != nil {
+			slog.ErrorContext(ctx, "Products failed", "err", err)
+			return nil, err
+		}
+		return products, nil
 	}
 
 	skip, take := uint64(0), uint64(0)
@@ -35,41 +135,144 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 		skip, take = pagination.bounds()
 	}
 
-	q := ""
+	q, category := "", ""
 	if query != nil {
 		q = *query
 	}
-	productList, err := r.server.catalogClient.GetProducts(ctx, q, nil, skip, take)
+	if categoryID != nil {
+		category = *categoryID
+	}
+	productList, err := r.server.catalogClient.GetProducts(ctx, q, category, nil, skip, take)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Products failed", "err", err)
 		return nil, err
 	}
 
 	var products []*Product
 	for _, a := range *productList {
-		products = append(products,
-			&Product{
-				ID:          a.ID,
-				Name:        a.Name,
-				Description: a.Description,
-				Price:       a.Price,
-			},
-		)
+		products = append(products, toProduct(&a))
+	}
+	if err := r.server.fillStock(ctx, products, store); err != nil {
+		slog.ErrorContext(ctx, "Products failed", "err", err)
+		return nil, err
 	}
 
 	return products, nil
 }
 
+func (r *queryResolver) Categories(ctx context.Context, rootID *string) ([]*Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	root := ""
+	if rootID != nil {
+		root = *rootID
+	}
+	nodes, err := r.server.catalogClient.GetCategoryTree(ctx, root)
+	if err != nil {
+		slog.ErrorContext(ctx, "Categories failed", "err", err)
+		return nil, err
+	}
+	return toCategoryTree(nodes), nil
+}
+
+// fillStock sets the stock fields of products, at storeID or across stores
+// when it is empty, with one call to the catalog service.
+func (s *Server) fillStock(ctx context.Context, products []*Product, storeID string) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := []string{}
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	stock, err := s.catalogClient.GetStock(ctx, storeID, ids)
+	if err != nil {
+		return err
+	}
+	quantities := map[string]int32{}
+	for _, st := range stock {
+		quantities[st.ProductID] = st.Quantity
+	}
+	for _, p := range products {
+		p.AvailableQuantity = int(quantities[p.ID])
+		p.InStock = p.Active && p.AvailableQuantity > 0
+	}
+	return nil
+}
+
+func (r *queryResolver) LowStock(ctx context.Context, storeID *string, threshold *int, pagination *PaginationInput) ([]*Stock, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	store, limit := "", int32(0)
+	if storeID != nil {
+		store = *storeID
+	}
+	if threshold != nil {
+		limit = int32(*threshold)
+	}
+	skip, take := uint64(0), uint64(0)
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+	stockList, err := r.server.catalogClient.ListLowStock(ctx, store, limit, skip, take)
+	if err != nil {
+		slog.ErrorContext(ctx, "LowStock failed", "err", err)
+		return nil, err
+	}
+	return toStock(stockList), nil
+}
+
+func toStock(stock []catalog.Stock) []*Stock {
+	out := []*Stock{}
+	for _, s := range stock {
+		st := &Stock{
+			ProductID: s.ProductID,
+			StoreID:   s.StoreID,
+			Quantity:  int(s.Quantity),
+		}
+		if !s.UpdatedAt.IsZero() {
+			updatedAt := s.UpdatedAt
+			st.UpdatedAt = &updatedAt
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func toProduct(p *catalog.Product) *Product {
+	product := &Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Mrp:         p.MRP,
+		Brand:       p.Brand,
+		Sku:         p.SKU,
+		Barcode:     p.Barcode,
+		CategoryIds: p.CategoryIDs,
+		ImageUrls:   p.ImageURLs,
+		Tags:        p.Tags,
+		Active:      p.Active,
+	}
+	if p.UnitMeasure != "" {
+		unitSize, unitMeasure := p.UnitSize, UnitMeasure(strings.ToUpper(p.UnitMeasure))
+		product.UnitSize, product.UnitMeasure = &unitSize, &unitMeasure
+	}
+	return product
+}
+
 func (r *queryResolver) Orders(ctx context.Context) ([]*Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	userID, ok := ctx.Value(UserIDKey).(string)
 	if !ok || userID == "" {
-		return nil, errors.New("unauthorized: user ID not found")
+		return nil, ErrUnauthorized
 	}
 	orderList, err := r.server.orderClient.GetOrdersForAccount(ctx, userID)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Orders failed", "err", err)
 		return nil, err
 	}
 
@@ -85,17 +288,106 @@ func (r *queryResolver) Orders(ctx context.Context) ([]*Order, error) {
 				Quantity:    int(p.Quantity),
 			})
 		}
-		orders = append(orders, &Order{
-			ID:         o.ID,
-			CreatedAt:  o.CreatedAt,
-			TotalPrice: o.TotalPrice,
-			Products:   products,
-		})
+		order := &Order{
+			ID:            o.ID,
+			CreatedAt:     o.CreatedAt,
+			TotalPrice:    o.TotalPrice,
+			PaymentMethod: toPaymentMethod(o.PaymentMethod),
+			Products:      products,
+		}
+		if o.StoreID != "" {
+			storeID := o.StoreID
+			order.StoreID = &storeID
+		}
+		orders = append(orders, order)
 	}
 
 	return orders, nil
 }
 
+func (r *queryResolver) MySessions(ctx context.Context) ([]*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+	currentSessionID, _ := ctx.Value(SessionIDKey).(string)
+
+	sessionList, err := r.server.accountClient.ListSessions(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "MySessions failed", "err", err)
+		return nil, err
+	}
+
+	sessions := []*Session{}
+	for _, s := range sessionList {
+		sessions = append(sessions, &Session{
+			ID:        s.ID,
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			CreatedAt: time.Unix(s.CreatedAt, 0).UTC(),
+			Current:   s.ID == currentSessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (r *queryResolver) Wallet(ctx context.Context) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	w, err := r.server.accountClient.GetWalletBalance(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Wallet failed", "err", err)
+		return nil, err
+	}
+
+	return &Wallet{
+		ID:       w.ID,
+		Currency: w.Currency,
+		Balance:  float64(w.Balance) / 100,
+	}, nil
+}
+
+func (r *queryResolver) WalletTransactions(ctx context.Context, pagination *PaginationInput) ([]*WalletTransaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	userID, ok := ctx.Value(UserIDKey).(string)
+	if !ok || userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	skip, take := uint64(0), uint64(0)
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+
+	transactionList, err := r.server.accountClient.ListWalletTransactions(ctx, userID, skip, take)
+	if err != nil {
+		slog.ErrorContext(ctx, "WalletTransactions failed", "err", err)
+		return nil, err
+	}
+
+	transactions := []*WalletTransaction{}
+	for _, t := range transactionList {
+		transactions = append(transactions, &WalletTransaction{
+			ID:        t.ID,
+			Kind:      t.Kind,
+			Amount:    float64(t.Amount) / 100,
+			Reference: t.Reference,
+			CreatedAt: time.Unix(t.CreatedAt, 0).UTC(),
+		})
+	}
+
+	return transactions, nil
+}
+
 func (p PaginationInput) bounds() (uint64, uint64) {
 	skipValue := uint64(0)
 	takeValue := uint64(100)
@@ -0,0 +1,80 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is a gqlgen extension that opens a span per operation, continuing
+// the caller's trace if the request carries one, and a child span per field
+// resolver. The gRPC calls resolvers make hang off these spans.
+type Tracing struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = Tracing{}
+
+func (Tracing) ExtensionName() string {
+	return "Tracing"
+}
+
+func (Tracing) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Tracing) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(oc.Headers))
+
+	opType, name := "unknown", oc.OperationName
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+	}
+	if name == "" {
+		name = "anonymous"
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "graphql "+opType+" "+name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.type", opType),
+			attribute.String("graphql.operation.name", name),
+		),
+	)
+
+	responses := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		res := responses(ctx)
+		if res != nil && len(res.Errors) > 0 {
+			span.SetStatus(codes.Error, res.Errors.Error())
+		}
+		span.End()
+		return res
+	}
+}
+
+func (Tracing) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(attribute.String("graphql.field.path", fc.Path().String())),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
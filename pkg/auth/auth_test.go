@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testKey = "test-service-key-0123456789abcdef"
+
+func testVerifier(ctx context.Context, token string) (*Claims, error) {
+	switch token {
+	case "customer":
+		return &Claims{UserID: "u1", Roles: []string{RoleCustomer}}, nil
+	case "admin":
+		return &Claims{UserID: "u2", Roles: []string{RoleAdmin}}, nil
+	case "forged":
+		return &Claims{UserID: "u3", Roles: []string{RoleService}}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	rules := map[string][]string{
+		"/pb.Test/Admin":   {RoleAdmin},
+		"/pb.Test/Own":     {},
+		"/pb.Test/Service": {RoleService},
+	}
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		want   codes.Code
+	}{
+		{name: "open method", method: "/pb.Test/Open", want: codes.OK},
+		{name: "no token", method: "/pb.Test/Own", want: codes.Unauthenticated},
+		{name: "invalid token", method: "/pb.Test/Own", md: metadata.Pairs("authorization", "Bearer nope"), want: codes.Unauthenticated},
+		{name: "any verified caller", method: "/pb.Test/Own", md: metadata.Pairs("authorization", "Bearer customer"), want: codes.OK},
+		{name: "missing role", method: "/pb.Test/Admin", md: metadata.Pairs("authorization", "Bearer customer"), want: codes.PermissionDenied},
+		{name: "role held", method: "/pb.Test/Admin", md: metadata.Pairs("authorization", "Bearer admin"), want: codes.OK},
+		{name: "service key", method: "/pb.Test/Service", md: metadata.Pairs(serviceKeyMetadata, testKey), want: codes.OK},
+		{name: "wrong service key", method: "/pb.Test/Service", md: metadata.Pairs(serviceKeyMetadata, "guess"), want: codes.Unauthenticated},
+		{name: "service key grants no user roles", method: "/pb.Test/Admin", md: metadata.Pairs(serviceKeyMetadata, testKey), want: codes.PermissionDenied},
+		{name: "user on service method", method: "/pb.Test/Service", md: metadata.Pairs("authorization", "Bearer admin"), want: codes.PermissionDenied},
+		{name: "service role in a token", method: "/pb.Test/Service", md: metadata.Pairs("authorization", "Bearer forged"), want: codes.PermissionDenied},
+	}
+	interceptor := UnaryServerInterceptor(testVerifier, testKey, rules)
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorWithoutKey(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testVerifier, "", map[string][]string{"/pb.Test/Service": {RoleService}})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(serviceKeyMetadata, ""))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pb.Test/Service"}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("empty key on a server without one: err = %v", err)
+	}
+}
+
+func TestRequireSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  *Claims
+		subject string
+		roles   []string
+		want    codes.Code
+	}{
+		{name: "no claims", subject: "u1", want: codes.Unauthenticated},
+		{name: "own account", claims: &Claims{UserID: "u1"}, subject: "u1", want: codes.OK},
+		{name: "other account", claims: &Claims{UserID: "u1"}, subject: "u2", want: codes.PermissionDenied},
+		{name: "role allowed", claims: &Claims{UserID: "u1", Roles: []string{RoleSupport}}, subject: "u2", roles: []string{RoleAdmin, RoleSupport}, want: codes.OK},
+		{name: "role not allowed", claims: &Claims{UserID: "u1", Roles: []string{RoleSupport}}, subject: "u2", roles: []string{RoleAdmin}, want: codes.PermissionDenied},
+		{name: "service has no subject", claims: &Claims{Roles: []string{RoleService}}, subject: "", want: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = context.WithValue(ctx, claimsKey, tt.claims)
+			}
+			if got := status.Code(RequireSubject(ctx, tt.subject, tt.roles...)); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
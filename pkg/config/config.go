@@ -0,0 +1,55 @@
+// Package config loads service configuration from an optional YAML file and
+// the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Validator is implemented by service config structs to reject missing or
+// out of range values before the service starts.
+type Validator interface {
+	Validate() error
+}
+
+// Load fills cfg from the YAML file at path, if any, and then from the
+// environment, so env vars win over the file and the file wins over the
+// defaults cfg already holds. YAML keys come from the yaml tags and env var
+// names from the envconfig tags.
+func Load(path string, cfg Validator) error {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := envconfig.Process("", cfg); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// Required returns an error naming every key whose value is empty.
+func Required(values map[string]string) error {
+	var missing []string
+	for key, value := range values {
+		if value == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return errors.New("missing required config: " + strings.Join(missing, ", "))
+}
@@ -0,0 +1,123 @@
+// Package errs defines domain errors that carry the gRPC status code they
+// map to, so handlers can return them as-is and clients see a proper code
+// instead of codes.Unknown.
+package errs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error is a domain error with a gRPC code. Field names the request field at
+// fault, if any, and is sent as a BadRequest field violation.
+type Error struct {
+	Code    codes.Code
+	Message string
+	Field   string
+}
+
+func New(code codes.Code, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+func NotFound(msg string) *Error {
+	return New(codes.NotFound, msg)
+}
+
+func AlreadyExists(field, msg string) *Error {
+	return &Error{Code: codes.AlreadyExists, Message: msg, Field: field}
+}
+
+func InvalidArgument(field, msg string) *Error {
+	return &Error{Code: codes.InvalidArgument, Message: msg, Field: field}
+}
+
+func Unauthenticated(msg string) *Error {
+	return New(codes.Unauthenticated, msg)
+}
+
+func PermissionDenied(msg string) *Error {
+	return New(codes.PermissionDenied, msg)
+}
+
+// Conflict is for requests that clash with existing state, such as reusing
+// an idempotency key for a different request.
+func Conflict(msg string) *Error {
+	return New(codes.Aborted, msg)
+}
+
+func FailedPrecondition(msg string) *Error {
+	return New(codes.FailedPrecondition, msg)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	if e.Field == "" {
+		return st
+	}
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: e.Field, Description: e.Message}},
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// FieldViolations returns the field violations attached to st.
+func FieldViolations(st *status.Status) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, br.FieldViolations...)
+		}
+	}
+	return violations
+}
+
+// CodeName formats a code the way GraphQL clients expect, e.g. NOT_FOUND.
+func CodeName(code codes.Code) string {
+	if code == codes.OK {
+		return "OK"
+	}
+	var b strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// UnaryServerInterceptor makes sure every error leaving a handler is a status
+// error. Errors without a code are logged and replaced with a generic
+// Internal error so database or Redis details don't reach clients.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, status.FromContextError(err).Err()
+		}
+		slog.ErrorContext(ctx, "Unhandled error", "method", info.FullMethod, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,148 @@
+// Package health reports whether a service's dependencies are reachable,
+// both through the standard grpc.health.v1 service and over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// checkInterval is how often Register reruns the checks
+	checkInterval = 5 * time.Second
+	// checkTimeout bounds a single check, so one hung dependency can't stall
+	// the others
+	checkTimeout = 2 * time.Second
+)
+
+// Check returns an error if a dependency is unavailable.
+type Check func(ctx context.Context) error
+
+// Checks are a service's dependency checks keyed by dependency name.
+type Checks map[string]Check
+
+// Run runs every check concurrently and returns the failures by name.
+func (c Checks) Run(ctx context.Context) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failures := map[string]error{}
+	for name, check := range c {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := check(ctx); err != nil {
+				mu.Lock()
+				failures[name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failures
+}
+
+// Register adds the grpc.health.v1 service to server. The overall status and
+// that of service are SERVING while all checks pass and NOT_SERVING
+// otherwise; they are refreshed in the background until ctx is done.
+func Register(ctx context.Context, server *grpc.Server, service string, checks Checks) *health.Server {
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(server, hs)
+
+	var last healthpb.HealthCheckResponse_ServingStatus
+	update := func() {
+		status := healthpb.HealthCheckResponse_SERVING
+		failures := checks.Run(ctx)
+		if len(failures) > 0 {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if status != last {
+			slog.InfoContext(ctx, "Health status changed", "grpc_service", service, "status", status.String())
+			for name, err := range failures {
+				slog.WarnContext(ctx, "Health check failed", "check", name, "err", err)
+			}
+			last = status
+		}
+		hs.SetServingStatus("", status)
+		hs.SetServingStatus(service, status)
+	}
+	update()
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				update()
+			}
+		}
+	}()
+	return hs
+}
+
+// GRPC checks a downstream server through its health service.
+func GRPC(conn grpc.ClientConnInterface, service string) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if res.Status != healthpb.HealthCheckResponse_SERVING {
+			return &notServingError{res.Status}
+		}
+		return nil
+	}
+}
+
+type notServingError struct {
+	status healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (e *notServingError) Error() string {
+	return "status " + e.status.String()
+}
+
+// Handler reports the checks as JSON, with status 503 if any of them fail or
+// once ctx is done and the server is shutting down.
+func Handler(ctx context.Context, checks Checks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks,omitempty"`
+		}{Status: "ok", Checks: map[string]string{}}
+		w.Header().Set("Content-Type", "application/json")
+		if ctx.Err() != nil {
+			res.Status = "shutting down"
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+
+		failures := checks.Run(r.Context())
+		for name := range checks {
+			res.Checks[name] = "ok"
+		}
+		code := http.StatusOK
+		for name, err := range failures {
+			res.Checks[name] = err.Error()
+			res.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(res)
+	})
+}
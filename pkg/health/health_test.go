@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		checks     Checks
+		shutdown   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "all passing", checks: Checks{"db": ok, "cache": ok}, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "one failing", checks: Checks{"db": ok, "cache": down}, wantCode: http.StatusServiceUnavailable, wantStatus: "unavailable"},
+		{name: "shutting down", checks: Checks{"db": ok}, shutdown: true, wantCode: http.StatusServiceUnavailable, wantStatus: "shutting down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.shutdown {
+				cancel()
+			}
+			rec := httptest.NewRecorder()
+			Handler(ctx, tt.checks).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var res struct {
+				Status string `json:"status"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", res.Status, tt.wantStatus)
+			}
+		})
+	}
+}
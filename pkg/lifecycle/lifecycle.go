@@ -0,0 +1,90 @@
+// Package lifecycle runs servers until the process is asked to stop and then
+// drains them, so in-flight requests finish and deferred cleanup runs.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+// SignalContext returns a context that is cancelled on SIGINT or SIGTERM.
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// ServeGRPC serves server on lis until ctx is done. It then reports
+// NOT_SERVING through hs and keeps serving for drainDelay, so clients and
+// load balancers notice and stop sending work, before waiting up to timeout
+// for in-flight RPCs and closing what's left.
+func ServeGRPC(ctx context.Context, server *grpc.Server, hs *health.Server, lis net.Listener, drainDelay, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(lis)
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	hs.Shutdown()
+	waitDrain(drainDelay)
+	slog.Info("Shutting down, draining in-flight RPCs")
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		slog.Warn("Drain timed out, closing remaining connections")
+		server.Stop()
+	}
+	return nil
+}
+
+// ServeHTTP serves srv until ctx is done. It keeps serving for drainDelay,
+// while readiness probes see ctx is done and fail, then stops accepting
+// connections and waits up to timeout for active requests to finish.
+func ServeHTTP(ctx context.Context, srv *http.Server, drainDelay, timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	waitDrain(drainDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Drain timed out, closing remaining connections")
+		return srv.Close()
+	}
+	return err
+}
+
+// waitDrain gives load balancers time to take the server out of rotation
+// after it starts reporting that it is shutting down.
+func waitDrain(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	slog.Info("Shutting down, waiting for traffic to drain", "delay", delay)
+	time.Sleep(delay)
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries the request ID in HTTP headers and, lowercased, in
+// gRPC metadata.
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDMetadata = strings.ToLower(RequestIDHeader)
+
+// UnaryServerInterceptor adopts the caller's request ID, or makes one up, and
+// logs every RPC with its status and duration. It should come after the
+// metrics interceptor and before errs so it logs the status the client sees.
+// Health checks aren't logged.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if ids := md.Get(requestIDMetadata); len(ids) > 0 && ValidRequestID(ids[0]) {
+				id = ids[0]
+			}
+		}
+		if id == "" {
+			id = NewRequestID()
+		}
+		ctx = WithRequestID(ctx, id)
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.") {
+			return res, err
+		}
+
+		code := status.Code(err)
+		attrs := []any{"method", info.FullMethod, "grpc_code", code.String(), "duration", time.Since(start)}
+		if err != nil {
+			attrs = append(attrs, "err", err)
+		}
+		slog.Log(ctx, serverLevel(code), "RPC handled", attrs...)
+		return res, err
+	}
+}
+
+// serverLevel logs failures that point at this service or its dependencies
+// as errors, and client mistakes such as NotFound as info.
+func serverLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded, codes.Unimplemented:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// UnaryClientInterceptor forwards the request ID to downstream services. It
+// comes from WithRequestID or, when a service calls another service, from
+// the request being handled.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadata, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,173 @@
+// Package logging sets up structured, leveled logging with log/slog. Log
+// lines carry the request ID and trace of the context they are logged with,
+// and attributes that would leak secrets or personal data are redacted.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options are embedded in each service's config.
+type Options struct {
+	// One of debug, info, warn or error; empty means info
+	Level string `envconfig:"LOG_LEVEL" yaml:"log_level"`
+	// json or text; empty means json
+	Format string `envconfig:"LOG_FORMAT" yaml:"log_format"`
+}
+
+func (o Options) Validate() error {
+	if _, err := o.level(); err != nil {
+		return err
+	}
+	switch o.Format {
+	case "", "json", "text":
+		return nil
+	}
+	return fmt.Errorf("unknown LOG_FORMAT %q", o.Format)
+}
+
+func (o Options) level() (slog.Level, error) {
+	var level slog.Level
+	if o.Level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return level, fmt.Errorf("unknown LOG_LEVEL %q", o.Level)
+	}
+	return level, nil
+}
+
+// Setup makes a logger tagged with service the default for slog and for the
+// standard log package, and returns it.
+func Setup(service string, opts Options) *slog.Logger {
+	logger := New(os.Stderr, opts).With("service", service)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New returns a logger writing to w with the context and redaction layers.
+func New(w io.Writer, opts Options) *slog.Logger {
+	level, err := opts.level()
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var h slog.Handler
+	if opts.Format == "text" {
+		h = slog.NewTextHandler(w, handlerOpts)
+	} else {
+		h = slog.NewJSONHandler(w, handlerOpts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Fatal logs msg at error level and exits, like log.Fatal.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID and trace of the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports whether a request ID supplied by a client is safe to
+// adopt: short and limited to letters, digits, '.', '_' and '-'.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// redact masks attributes whose key names a secret or personal data, so a
+// stray slog.Any("password", ...) can't leak.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case strings.Contains(key, "password"), strings.Contains(key, "token"), strings.Contains(key, "secret"),
+		key == "authorization", key == "otp", key == "code":
+		return slog.String(a.Key, "[REDACTED]")
+	case key == "email":
+		return slog.String(a.Key, maskEmail(a.Value.String()))
+	case key == "phone":
+		return slog.String(a.Key, maskTail(a.Value.String()))
+	case key == "identifier" || key == "emailorphone":
+		if v := a.Value.String(); strings.Contains(v, "@") {
+			return slog.String(a.Key, maskEmail(v))
+		}
+		return slog.String(a.Key, maskTail(a.Value.String()))
+	}
+	return a
+}
+
+// maskEmail keeps the first letter of the local part and the domain, e.g.
+// "jane@example.com" becomes "j***@example.com".
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskTail keeps the last four characters, e.g. of a phone number.
+func maskTail(s string) string {
+	if len(s) <= 4 {
+		return "***"
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
@@ -0,0 +1,95 @@
+// Package metrics exports Prometheus metrics for gRPC traffic, datastore
+// queries and Redis calls, served from a port of their own.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcServerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Time taken to handle unary RPCs.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_service", "grpc_method"})
+	grpcServerHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Unary RPCs handled, by status code.",
+	}, []string{"grpc_service", "grpc_method", "grpc_code"})
+	grpcClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "Time taken for unary RPCs to downstream services to complete.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_service", "grpc_method"})
+	grpcClientHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "Unary RPCs to downstream services completed, by status code.",
+	}, []string{"grpc_service", "grpc_method", "grpc_code"})
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by repository operations, by datastore.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"store", "operation"})
+)
+
+// UnaryServerInterceptor records the latency and status code of every RPC.
+// It should come first in the chain so it sees the final status.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		service, method := splitMethod(info.FullMethod)
+		grpcServerDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+		grpcServerHandled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+		return res, err
+	}
+}
+
+// UnaryClientInterceptor records the latency and status code of every call to
+// a downstream service.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		service, name := splitMethod(method)
+		grpcClientDuration.WithLabelValues(service, name).Observe(time.Since(start).Seconds())
+		grpcClientHandled.WithLabelValues(service, name, status.Code(err).String()).Inc()
+		return err
+	}
+}
+
+// splitMethod splits "/pb.AccountService/GetAccount" into its service and
+// method names.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", fullMethod
+	}
+	return service, method
+}
+
+// ObserveQuery records how long a repository operation took against store,
+// counting from start. Repositories get it through tracing.Query along with
+// the operation's span.
+func ObserveQuery(store, operation string, start time.Time) {
+	queryDuration.WithLabelValues(store, operation).Observe(time.Since(start).Seconds())
+}
+
+// Serve serves the metrics at /metrics on port until ctx is done.
+func Serve(ctx context.Context, port int, shutdownTimeout time.Duration) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	return lifecycle.ServeHTTP(ctx, srv, 0, shutdownTimeout)
+}
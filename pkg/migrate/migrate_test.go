@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"m/0010_later.up.sql":   {Data: []byte("SELECT 10")},
+				"m/0002_second.up.sql":  {Data: []byte("SELECT 2")},
+				"m/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+				"m/0001_init.down.sql":  {Data: []byte("SELECT -1")},
+				"m/README.md":           {Data: []byte("not a migration")},
+				"m/0003_no_suffix.sql":  {Data: []byte("ignored")},
+				"m/0002_second.down.sq": {Data: []byte("ignored")},
+			},
+			want: []string{"init", "second", "later"},
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"m/0001_init.down.sql": {Data: []byte("SELECT -1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "two names for a version",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":  {Data: []byte("SELECT 1")},
+				"m/0001_other.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(nil, "test", tt.files, "m")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, mig := range m.migrations {
+				names = append(names, mig.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("migrations = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFunc(t *testing.T) {
+	files := fstest.MapFS{
+		"m/0001_init.up.sql":      {Data: []byte("SELECT 1")},
+		"m/0003_backfill.up.sql":  {Data: []byte("SELECT 3")},
+		"m/0005_cleanup.up.sql":   {Data: []byte("SELECT 5")},
+		"m/0005_cleanup.down.sql": {Data: []byte("SELECT -5")},
+	}
+	fn := func(ctx context.Context, tx *sql.Tx) error { return nil }
+
+	tests := []struct {
+		name    string
+		version int64
+		fnName  string
+		want    []int64
+		wantErr bool
+	}{
+		{name: "attached to a script", version: 3, fnName: "backfill", want: []int64{1, 3, 5}},
+		{name: "on its own", version: 4, fnName: "go_only", want: []int64{1, 3, 4, 5}},
+		{name: "name mismatch", version: 3, fnName: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(nil, "test", files, "m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.AddFunc(tt.version, tt.fnName, fn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddFunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var versions []int64
+			for _, mig := range m.migrations {
+				versions = append(versions, mig.Version)
+				if mig.Version == tt.version && mig.UpFunc == nil {
+					t.Errorf("migration %d has no UpFunc", mig.Version)
+				}
+			}
+			if !slices.Equal(versions, tt.want) {
+				t.Errorf("versions = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
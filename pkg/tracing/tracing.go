@@ -0,0 +1,166 @@
+// Package tracing sets up OpenTelemetry tracing so a request can be followed
+// from the gateway through every gRPC hop down to the datastores.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const (
+	tracerName = "github.com/theshubhamy/microGo/pkg/tracing"
+	// flushTimeout bounds how long exiting waits on the exporter
+	flushTimeout = 5 * time.Second
+)
+
+// Options choose where spans are exported. They are embedded in each
+// service's config.
+type Options struct {
+	// One of otlp, stdout, file or none; empty means none
+	Exporter string `envconfig:"TRACE_EXPORTER" yaml:"trace_exporter"`
+	// Where the file exporter writes spans, one JSON object per span
+	File string `envconfig:"TRACE_FILE" yaml:"trace_file"`
+	// Collector URL for the otlp exporter, e.g. http://otel-collector:4317.
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	OTLPEndpoint string `envconfig:"OTLP_ENDPOINT" yaml:"otlp_endpoint"`
+}
+
+func (o Options) Validate() error {
+	switch o.Exporter {
+	case "", "none", "otlp", "stdout":
+		return nil
+	case "file":
+		if o.File == "" {
+			return errors.New("TRACE_FILE is required with the file trace exporter")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown TRACE_EXPORTER %q", o.Exporter)
+}
+
+// Setup installs the global tracer provider and W3C trace context propagation
+// for service. The returned func flushes buffered spans and should be
+// deferred by main.
+func Setup(ctx context.Context, service string, opts Options) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+	switch opts.Exporter {
+	case "", "none":
+		// The global provider stays a no-op, but incoming trace context is
+		// still passed on to downstream calls
+		return func() {}, nil
+	case "otlp":
+		var clientOpts []otlptracegrpc.Option
+		if opts.OTLPEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracegrpc.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		exporter, err = otlptracegrpc.New(ctx, clientOpts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		var f *os.File
+		f, err = os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown TRACE_EXPORTER %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Error("Error flushing traces", "err", err)
+		}
+		if closer != nil {
+			closer.Close()
+		}
+	}, nil
+}
+
+// Tracer returns the tracer for spans created by this repo's code.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// ServerOption traces incoming RPCs, continuing the caller's trace. Health
+// checks are left out as they would drown everything else.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithFilter(filters.Not(filters.HealthCheck()))))
+}
+
+// DialOption traces outgoing RPCs and passes the trace on in the request
+// metadata.
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithFilter(filters.Not(filters.HealthCheck()))))
+}
+
+// Query starts a span for a repository operation against store and returns
+// the context carrying it along with a func to call with the operation's
+// result. That func ends the span, marking it failed when err is set, and
+// records the duration in the db_query_duration_seconds metric. Methods call
+// it first thing, with a named error result:
+//
+//	ctx, done := tracing.Query(ctx, "postgresql", "GetAccount")
+//	defer func() { done(err) }()
+func Query(ctx context.Context, store, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := Tracer().Start(ctx, store+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", store),
+			attribute.String("db.operation.name", operation),
+		),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		metrics.ObserveQuery(store, operation, start)
+	}
+}
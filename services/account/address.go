@@ -0,0 +1,81 @@
+package account
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+var (
+	ErrAddressNotFound = errs.NotFound("address not found")
+	ErrInvalidAddress  = errs.InvalidArgument("address", "address needs a label, line1, city, state, a 6 digit pincode and valid coordinates")
+)
+
+var pincodeRegex = regexp.MustCompile(`^[1-9][0-9]{5}$`)
+
+type Address struct {
+	ID        string  `json:"id"`
+	AccountID string  `json:"accountId"`
+	Label     string  `json:"label"`
+	Line1     string  `json:"line1"`
+	Line2     string  `json:"line2"`
+	Landmark  string  `json:"landmark"`
+	City      string  `json:"city"`
+	State     string  `json:"state"`
+	Pincode   string  `json:"pincode"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	IsDefault bool    `json:"isDefault"`
+}
+
+func (a Address) validate() error {
+	if strings.TrimSpace(a.Label) == "" || strings.TrimSpace(a.Line1) == "" ||
+		strings.TrimSpace(a.City) == "" || strings.TrimSpace(a.State) == "" ||
+		!pincodeRegex.MatchString(a.Pincode) ||
+		a.Latitude < -90 || a.Latitude > 90 || a.Longitude < -180 || a.Longitude > 180 {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// CreateAddress adds an address to the account. The first address of an
+// account always becomes its default.
+func (as *accountService) CreateAddress(ctx context.Context, address Address) (*Address, error) {
+	if err := address.validate(); err != nil {
+		return nil, err
+	}
+	address.ID = ksuid.New().String()
+
+	existing, err := as.repository.ListAddresses(ctx, address.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	address.IsDefault = len(existing) == 0
+
+	if err := as.repository.PutAddress(ctx, address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (as *accountService) ListAddresses(ctx context.Context, accountID string) ([]Address, error) {
+	return as.repository.ListAddresses(ctx, accountID)
+}
+
+func (as *accountService) UpdateAddress(ctx context.Context, address Address) (*Address, error) {
+	if err := address.validate(); err != nil {
+		return nil, err
+	}
+	return as.repository.UpdateAddress(ctx, address)
+}
+
+func (as *accountService) DeleteAddress(ctx context.Context, accountID, addressID string) error {
+	return as.repository.DeleteAddress(ctx, accountID, addressID)
+}
+
+func (as *accountService) SetDefaultAddress(ctx context.Context, accountID, addressID string) (*Address, error) {
+	return as.repository.SetDefaultAddress(ctx, accountID, addressID)
+}
@@ -2,7 +2,13 @@ package account
 
 import (
 	"context"
+	"time"
 
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/account/pb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -13,8 +19,14 @@ type Client struct {
 	service pb.AccountServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewClient connects to the account service. Services pass
+// auth.ServiceCredentials in opts to call service-only RPCs.
+func NewClient(url string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(url, append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(), logging.UnaryClientInterceptor(), auth.UnaryClientInterceptor()),
+	}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +38,11 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Ping checks the account service through its gRPC health service.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.GRPC(c.conn, pb.AccountService_ServiceDesc.ServiceName)(ctx)
+}
+
 func (c *Client) PostAccount(ctx context.Context, name, email, phone, password string) (*Account, error) {
 	r, err := c.service.PostAccount(ctx, &pb.PostAccountRequest{Name: name, Email: email, Phone: phone, Password: password})
 	if err != nil {
@@ -54,30 +71,264 @@ func (c *Client) LoginAccount(ctx context.Context, emailorphone, password, ip, u
 	return account, res.AccessToken, res.RefreshToken, nil
 }
 
+func (c *Client) RequestOTP(ctx context.Context, identifier string) error {
+	_, err := c.service.RequestOTP(ctx, &pb.RequestOTPRequest{Identifier: identifier})
+	return err
+}
+
+func (c *Client) VerifyOTP(ctx context.Context, identifier, code, ip, userAgent string) (*Account, string, string, error) {
+	res, err := c.service.VerifyOTP(ctx, &pb.VerifyOTPRequest{Identifier: identifier, Code: code, Ip: ip, UserAgent: userAgent})
+	if err != nil {
+		return nil, "", "", err
+	}
+	account := &Account{
+		ID:    res.Id,
+		Name:  res.Name,
+		Email: res.Email,
+		Phone: res.Phone,
+	}
+
+	return account, res.AccessToken, res.RefreshToken, nil
+}
+
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
+	res, err := c.service.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: refreshToken})
+	if err != nil {
+		return "", "", err
+	}
+	return res.AccessToken, res.RefreshToken, nil
+}
+
+func (c *Client) Logout(ctx context.Context, userID, sessionID string) error {
+	_, err := c.service.Logout(ctx, &pb.LogoutRequest{UserId: userID, SessionId: sessionID})
+	return err
+}
+
+func (c *Client) LogoutAll(ctx context.Context, userID string) error {
+	_, err := c.service.LogoutAll(ctx, &pb.LogoutAllRequest{UserId: userID})
+	return err
+}
+
+func (c *Client) ListSessions(ctx context.Context, userID string) ([]SessionData, error) {
+	res, err := c.service.ListSessions(ctx, &pb.ListSessionsRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	sessions := []SessionData{}
+	for _, s := range res.Sessions {
+		sessions = append(sessions, SessionData{
+			ID:        s.Id,
+			UserID:    userID,
+			IP:        s.Ip,
+			UserAgent: s.UserAgent,
+			CreatedAt: s.CreatedAt,
+		})
+	}
+	return sessions, nil
+}
+
 func (c *Client) GetAccount(ctx context.Context, id string) (*Account, error) {
 	r, err := c.service.GetAccount(ctx, &pb.GetAccountRequest{Id: id})
 	if err != nil {
 		return nil, err
 	}
 	return &Account{
-		ID:    r.Id,
-		Name:  r.Name,
-		Email: r.Email,
-		Phone: r.Phone,
+		ID:            r.Id,
+		Name:          r.Name,
+		Email:         r.Email,
+		Phone:         r.Phone,
+		EmailVerified: r.EmailVerified,
+		PhoneVerified: r.PhoneVerified,
+		Roles:         r.Roles,
+		CreatedAt:     time.Unix(r.CreatedAt, 0),
 	}, nil
 }
 
-func (c *Client) GetAccounts(ctx context.Context, skip uint64, take uint64) (*[]Account, error) {
-	res, err := c.service.GetAccounts(ctx, &pb.GetAccountsRequest{Skip: skip, Take: take})
+func (c *Client) SetAccountRoles(ctx context.Context, id string, roles []string) error {
+	_, err := c.service.SetAccountRoles(ctx, &pb.SetAccountRolesRequest{Id: id, Roles: roles})
+	return err
+}
+
+func (c *Client) SendVerification(ctx context.Context, accountID, channel string) error {
+	_, err := c.service.SendVerification(ctx, &pb.SendVerificationRequest{AccountId: accountID, Channel: channel})
+	return err
+}
+
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+	_, err := c.service.VerifyEmail(ctx, &pb.VerifyEmailRequest{Token: token})
+	return err
+}
+
+func (c *Client) VerifyPhone(ctx context.Context, accountID, code string) error {
+	_, err := c.service.VerifyPhone(ctx, &pb.VerifyPhoneRequest{AccountId: accountID, Code: code})
+	return err
+}
+
+func (c *Client) UpdateAccount(ctx context.Context, id, name, email, phone string) (*Account, error) {
+	r, err := c.service.UpdateAccount(ctx, &pb.UpdateAccountRequest{Id: id, Name: name, Email: email, Phone: phone})
 	if err != nil {
 		return nil, err
 	}
+	return &Account{
+		ID:            r.Account.Id,
+		Name:          r.Account.Name,
+		Email:         r.Account.Email,
+		Phone:         r.Account.Phone,
+		EmailVerified: r.Account.EmailVerified,
+		PhoneVerified: r.Account.PhoneVerified,
+		Roles:         r.Account.Roles,
+	}, nil
+}
+
+func (c *Client) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	_, err := c.service.ChangePassword(ctx, &pb.ChangePasswordRequest{Id: id, OldPassword: oldPassword, NewPassword: newPassword})
+	return err
+}
+
+func (c *Client) DeleteAccount(ctx context.Context, id string) error {
+	_, err := c.service.DeleteAccount(ctx, &pb.DeleteAccountRequest{Id: id})
+	return err
+}
+
+// GetAccounts returns a page of accounts matching filter and the cursor for
+// the next page, which is empty on the last one.
+func (c *Client) GetAccounts(ctx context.Context, filter AccountFilter, after string, skip uint64, take uint64) ([]Account, string, error) {
+	req := &pb.GetAccountsRequest{
+		Skip:          skip,
+		Take:          take,
+		Query:         filter.Query,
+		EmailVerified: filter.EmailVerified,
+		PhoneVerified: filter.PhoneVerified,
+		After:         after,
+	}
+	if !filter.CreatedAfter.IsZero() {
+		req.CreatedAfter = filter.CreatedAfter.Unix()
+	}
+	if !filter.CreatedBefore.IsZero() {
+		req.CreatedBefore = filter.CreatedBefore.Unix()
+	}
+	switch filter.Deletion {
+	case DeletionDeleted:
+		req.Deletion = pb.DeletionFilter_DELETION_DELETED
+	case DeletionAny:
+		req.Deletion = pb.DeletionFilter_DELETION_ANY
+	}
+
+	res, err := c.service.GetAccounts(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
 	accounts := []Account{}
 	for _, acc := range res.Accounts {
 		accounts = append(accounts, Account{
-			ID:   acc.Id,
-			Name: acc.Name,
+			ID:            acc.Id,
+			Name:          acc.Name,
+			Email:         acc.Email,
+			Phone:         acc.Phone,
+			EmailVerified: acc.EmailVerified,
+			PhoneVerified: acc.PhoneVerified,
+			Roles:         acc.Roles,
+			CreatedAt:     time.Unix(acc.CreatedAt, 0),
+			Deleted:       acc.Deleted,
 		})
 	}
-	return &accounts, nil
+	return accounts, res.NextCursor, nil
+}
+
+// TopUpWallet credits the wallet and returns the transaction and new balance.
+func (c *Client) TopUpWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, int64, error) {
+	res, err := c.service.TopUpWallet(ctx, &pb.WalletPostingRequest{AccountId: accountID, Amount: amount, IdempotencyKey: idempotencyKey, Reference: reference})
+	if err != nil {
+		return nil, 0, err
+	}
+	return walletTransactionFromProto(res.Transaction), res.Balance, nil
+}
+
+// DebitWallet charges the wallet and returns the transaction and new balance.
+func (c *Client) DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, int64, error) {
+	res, err := c.service.DebitWallet(ctx, &pb.WalletPostingRequest{AccountId: accountID, Amount: amount, IdempotencyKey: idempotencyKey, Reference: reference})
+	if err != nil {
+		return nil, 0, err
+	}
+	return walletTransactionFromProto(res.Transaction), res.Balance, nil
+}
+
+func (c *Client) GetWalletBalance(ctx context.Context, accountID string) (*Wallet, error) {
+	res, err := c.service.GetWalletBalance(ctx, &pb.GetWalletBalanceRequest{AccountId: accountID})
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{
+		ID:        res.WalletId,
+		AccountID: accountID,
+		Currency:  res.Currency,
+		Balance:   res.Balance,
+	}, nil
+}
+
+func (c *Client) ListWalletTransactions(ctx context.Context, accountID string, skip, take uint64) ([]WalletTransaction, error) {
+	res, err := c.service.ListWalletTransactions(ctx, &pb.ListWalletTransactionsRequest{AccountId: accountID, Skip: skip, Take: take})
+	if err != nil {
+		return nil, err
+	}
+	transactions := []WalletTransaction{}
+	for _, t := range res.Transactions {
+		transactions = append(transactions, *walletTransactionFromProto(t))
+	}
+	return transactions, nil
+}
+
+func walletTransactionFromProto(t *pb.WalletTransaction) *WalletTransaction {
+	return &WalletTransaction{
+		ID:        t.Id,
+		WalletID:  t.WalletId,
+		Kind:      t.Kind,
+		Amount:    t.Amount,
+		Reference: t.Reference,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (c *Client) CreateAddress(ctx context.Context, address Address) (*Address, error) {
+	res, err := c.service.CreateAddress(ctx, &pb.AddressRequest{Address: addressToProto(&address)})
+	if err != nil {
+		return nil, err
+	}
+	a := addressFromProto(res.Address)
+	return &a, nil
+}
+
+func (c *Client) ListAddresses(ctx context.Context, accountID string) ([]Address, error) {
+	res, err := c.service.ListAddresses(ctx, &pb.ListAddressesRequest{AccountId: accountID})
+	if err != nil {
+		return nil, err
+	}
+	addresses := []Address{}
+	for _, a := range res.Addresses {
+		addresses = append(addresses, addressFromProto(a))
+	}
+	return addresses, nil
+}
+
+func (c *Client) UpdateAddress(ctx context.Context, address Address) (*Address, error) {
+	res, err := c.service.UpdateAddress(ctx, &pb.AddressRequest{Address: addressToProto(&address)})
+	if err != nil {
+		return nil, err
+	}
+	a := addressFromProto(res.Address)
+	return &a, nil
+}
+
+func (c *Client) DeleteAddress(ctx context.Context, accountID, addressID string) error {
+	_, err := c.service.DeleteAddress(ctx, &pb.AddressIdRequest{AccountId: accountID, AddressId: addressID})
+	return err
+}
+
+func (c *Client) SetDefaultAddress(ctx context.Context, accountID, addressID string) (*Address, error) {
+	res, err := c.service.SetDefaultAddress(ctx, &pb.AddressIdRequest{AccountId: accountID, AddressId: addressID})
+	if err != nil {
+		return nil, err
+	}
+	a := addressFromProto(res.Address)
+	return &a, nil
 }
@@ -1,32 +1,156 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/migrate"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/account"
 	"github.com/tinrab/retry"
 )
 
 func main() {
-	account.LoadConfig()
-	redisClient := account.InitRedis(account.AppConfig.REDIS_URL)
+	cfg, err := account.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logging.Fatal("Failed to load config", "err", err)
+	}
+	logging.Setup("account", cfg.Logging)
+	flushTraces, err := tracing.Setup(context.Background(), "account", cfg.Options)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "err", err)
+	}
+	defer flushTraces()
+
+	contacts, err := account.NewContactNormalizer(cfg.DefaultPhoneRegion)
+	if err != nil {
+		logging.Fatal("Failed to set up contact normalization", "err", err)
+	}
+
+	var migrator *migrate.Migrator
+	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
+		migrator, err = account.NewMigrator(cfg.DatabaseURL, contacts)
+		if err != nil {
+			slog.Error("Failed to connect to Postgres", "err", err)
+		}
+		return
+	})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate.Command(context.Background(), migrator, os.Args[2:], os.Stdout)
+		migrator.Close()
+		if err != nil {
+			logging.Fatal("Migration failed", "err", err)
+		}
+		return
+	}
+	applied, err := migrator.Up(context.Background())
+	migrator.Close()
+	if err != nil {
+		logging.Fatal("Failed to migrate database", "err", err)
+	}
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+	}
+	keys, err := account.LoadSigningKeys(cfg.JWTKeyDir, cfg.JWTSigningKID)
+	if err != nil {
+		logging.Fatal("Failed to load signing keys", "err", err)
+	}
+	tokens := account.NewTokenIssuer(cfg, keys)
+	passwords, err := account.NewPasswordPolicy(cfg.PasswordMinLength, cfg.BreachedPasswordsFile)
+	if err != nil {
+		logging.Fatal("Failed to load password policy", "err", err)
+	}
+
+	redisClient := account.InitRedis(cfg.RedisURL)
 	defer func() {
 		if err := redisClient.Close(); err != nil {
-			log.Printf("Error closing Redis client: %v", err)
+			slog.Error("Error closing Redis client", "err", err)
 		}
 	}()
 
 	var accRepo account.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
-		accRepo, err = account.NewPostgresRepository(account.AppConfig.DATABASE_URL)
+		accRepo, err = account.NewPostgresRepository(cfg.DatabaseURL)
 		if err != nil {
-			log.Println(err)
+			slog.Error("Failed to connect to Postgres", "err", err)
 		}
 		return
 	})
 	defer accRepo.Close()
-	log.Println("Server running at 8080 ...")
-	s := account.NewService(accRepo, redisClient)
-	log.Fatal(account.ListenGrpcServer(s, 8080))
+	if cfg.OTPLogPlaintext {
+		slog.Warn("One-time codes are written in plain text, don't use OTP_LOG_PLAINTEXT in production")
+	}
+	otpSender := account.NewLogOTPSender(cfg.OTPOutboxFile, cfg.OTPLogPlaintext)
+	s := account.NewService(cfg, accRepo, redisClient, otpSender, tokens, passwords, contacts)
+	slog.Info("Server running", "port", cfg.Port)
+
+	// Everything below stops on SIGTERM; the deferred closes above run once
+	// the background work has finished
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Scrub PII from accounts whose deletion grace period has passed
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(cfg.AnonymizeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := s.AnonymizeDeletedAccounts(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error anonymizing deleted accounts", "err", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Anonymized deleted accounts", "count", n)
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := metrics.Serve(ctx, cfg.MetricsPort, cfg.ShutdownTimeout); err != nil {
+			logging.Fatal("Metrics server failed", "err", err)
+		}
+	}()
+
+	// Publish the public signing keys for other services to verify tokens
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mux := http.NewServeMux()
+		mux.Handle("/.well-known/jwks.json", account.JWKSHandler(keys))
+		jwksServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.JWKSPort), Handler: mux}
+		if err := lifecycle.ServeHTTP(ctx, jwksServer, cfg.DrainDelay, cfg.ShutdownTimeout); err != nil {
+			logging.Fatal("JWKS server failed", "err", err)
+		}
+	}()
+
+	checks := health.Checks{
+		"postgres": accRepo.Ping,
+		"redis": func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		},
+	}
+	if err := account.ListenGrpcServer(ctx, s, account.SessionVerifier(tokens.VerifyAccessToken, redisClient), cfg.ServiceKey, checks, cfg.Port, cfg.DrainDelay, cfg.ShutdownTimeout); err != nil {
+		logging.Fatal("Server failed", "err", err)
+	}
+	slog.Info("Server stopped")
 }
@@ -2,36 +2,162 @@ package account
 
 import (
 	"context"
-	"log"
+	"errors"
+	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/theshubhamy/microGo/pkg/config"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 )
 
+// Config is the account service configuration. Load it with LoadConfig and
+// pass it to NewService and NewTokenIssuer.
 type Config struct {
-	DATABASE_URL       string `envconfig:"DATABASE_URL"`
-	JWT_SECRET         string `envconfig:"JWT_SECRET"`
-	REFRESH_JWT_SECRET string `envconfig:"REFRESH_JWT_SECRET"`
-	REDIS_URL          string `envconfig:"REDIS_URL"`
+	Port                  int    `envconfig:"PORT" yaml:"port"`
+	JWKSPort              int    `envconfig:"JWKS_PORT" yaml:"jwks_port"`
+	MetricsPort           int    `envconfig:"METRICS_PORT" yaml:"metrics_port"`
+	DatabaseURL           string `envconfig:"DATABASE_URL" yaml:"database_url"`
+	RedisURL              string `envconfig:"REDIS_URL" yaml:"redis_url"`
+	JWTKeyDir             string `envconfig:"JWT_KEY_DIR" yaml:"jwt_key_dir"`
+	JWTSigningKID         string `envconfig:"JWT_SIGNING_KID" yaml:"jwt_signing_kid"`
+	RefreshJWTSecret      string `envconfig:"REFRESH_JWT_SECRET" yaml:"refresh_jwt_secret"`
+	VerificationJWTSecret string `envconfig:"VERIFICATION_JWT_SECRET" yaml:"verification_jwt_secret"`
+	// Shared with the services allowed to post wallet transactions
+	ServiceKey string `envconfig:"SERVICE_KEY" yaml:"service_key"`
+	// Lifetimes of tokens, sessions and one-time codes
+	EmailVerificationTTL time.Duration `envconfig:"EMAIL_VERIFICATION_TTL" yaml:"email_verification_ttl"`
+	AccessTokenTTL       time.Duration `envconfig:"ACCESS_TOKEN_TTL" yaml:"access_token_ttl"`
+	RefreshTokenTTL      time.Duration `envconfig:"REFRESH_TOKEN_TTL" yaml:"refresh_token_ttl"`
+	SessionTTL           time.Duration `envconfig:"SESSION_TTL" yaml:"session_ttl"`
+	OTPTTL               time.Duration `envconfig:"OTP_TTL" yaml:"otp_ttl"`
+	OTPMaxAttempts       int           `envconfig:"OTP_MAX_ATTEMPTS" yaml:"otp_max_attempts"`
+	OTPResendCooldown    time.Duration `envconfig:"OTP_RESEND_COOLDOWN" yaml:"otp_resend_cooldown"`
+	OTPOutboxFile        string        `envconfig:"OTP_OUTBOX_FILE" yaml:"otp_outbox_file"`
+	// Development only: write one-time codes in plain text to OTP_OUTBOX_FILE,
+	// or stderr, instead of logging just where they went
+	OTPLogPlaintext   bool `envconfig:"OTP_LOG_PLAINTEXT" yaml:"otp_log_plaintext"`
+	PasswordMinLength int  `envconfig:"PASSWORD_MIN_LENGTH" yaml:"password_min_length"`
+	// Optional list of breached passwords or their SHA-1 digests, one per line
+	BreachedPasswordsFile string `envconfig:"BREACHED_PASSWORDS_FILE" yaml:"breached_passwords_file"`
+	// Region assumed for phone numbers given without a country code
+	DefaultPhoneRegion string `envconfig:"DEFAULT_PHONE_REGION" yaml:"default_phone_region"`
+	// Login brute-force protection
+	LoginMaxFailures   int           `envconfig:"LOGIN_MAX_FAILURES" yaml:"login_max_failures"`
+	LoginIPMaxFailures int           `envconfig:"LOGIN_IP_MAX_FAILURES" yaml:"login_ip_max_failures"`
+	LoginFailureWindow time.Duration `envconfig:"LOGIN_FAILURE_WINDOW" yaml:"login_failure_window"`
+	LoginLockBase      time.Duration `envconfig:"LOGIN_LOCK_BASE" yaml:"login_lock_base"`
+	LoginLockMax       time.Duration `envconfig:"LOGIN_LOCK_MAX" yaml:"login_lock_max"`
+	// Soft-deleted accounts are anonymized after the grace period
+	AccountDeletionGrace time.Duration `envconfig:"ACCOUNT_DELETION_GRACE" yaml:"account_deletion_grace"`
+	AnonymizeInterval    time.Duration `envconfig:"ANONYMIZE_INTERVAL" yaml:"anonymize_interval"`
+	// How long to keep serving after SIGTERM while reporting not ready, so
+	// load balancers stop sending new requests
+	DrainDelay time.Duration `envconfig:"DRAIN_DELAY" yaml:"drain_delay"`
+	// How long in-flight requests may run on after SIGTERM
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	tracing.Options `yaml:",inline"`
+	Logging         logging.Options `yaml:",inline"`
 }
 
-var AppConfig Config
+// DefaultConfig returns the values used when neither the config file nor
+// the environment sets them.
+func DefaultConfig() Config {
+	return Config{
+		Port:                 8080,
+		JWKSPort:             8081,
+		MetricsPort:          9090,
+		EmailVerificationTTL: 24 * time.Hour,
+		AccessTokenTTL:       time.Hour,
+		RefreshTokenTTL:      7 * 24 * time.Hour,
+		SessionTTL:           7 * 24 * time.Hour,
+		OTPTTL:               5 * time.Minute,
+		OTPMaxAttempts:       5,
+		OTPResendCooldown:    time.Minute,
+		PasswordMinLength:    8,
+		DefaultPhoneRegion:   "IN",
+		LoginMaxFailures:     5,
+		LoginIPMaxFailures:   20,
+		LoginFailureWindow:   15 * time.Minute,
+		LoginLockBase:        time.Minute,
+		LoginLockMax:         time.Hour,
+		AccountDeletionGrace: 30 * 24 * time.Hour,
+		AnonymizeInterval:    time.Hour,
+		DrainDelay:           5 * time.Second,
+		ShutdownTimeout:      15 * time.Second,
+	}
+}
+
+// LoadConfig overlays the YAML file at path, if any, and the environment on
+// DefaultConfig. It fails when a secret is missing or the token, session and
+// lockout settings contradict each other.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	err := config.Load(path, &cfg)
+	return cfg, err
+}
 
-func LoadConfig() {
-	err := envconfig.Process("", &AppConfig)
+func (c *Config) Validate() error {
+	err := config.Required(map[string]string{
+		"DATABASE_URL":            c.DatabaseURL,
+		"REDIS_URL":               c.RedisURL,
+		"REFRESH_JWT_SECRET":      c.RefreshJWTSecret,
+		"VERIFICATION_JWT_SECRET": c.VerificationJWTSecret,
+		"SERVICE_KEY":             c.ServiceKey,
+	})
 	if err != nil {
-		log.Fatal("Failed to load environment config:", err)
+		return err
+	}
+	if len(c.ServiceKey) < 32 {
+		return errors.New("SERVICE_KEY must be at least 32 characters")
+	}
+	if c.AccessTokenTTL <= 0 || c.RefreshTokenTTL <= 0 || c.SessionTTL <= 0 {
+		return errors.New("token and session TTLs must be positive")
+	}
+	if c.RefreshTokenTTL > c.SessionTTL {
+		return errors.New("REFRESH_TOKEN_TTL must not outlive SESSION_TTL")
+	}
+	if c.PasswordMinLength < 8 {
+		return errors.New("PASSWORD_MIN_LENGTH must be at least 8")
+	}
+	if _, err := NewContactNormalizer(c.DefaultPhoneRegion); err != nil {
+		return err
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.DrainDelay < 0 {
+		return errors.New("DRAIN_DELAY must not be negative")
+	}
+	if err := c.Options.Validate(); err != nil {
+		return err
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	if c.OTPMaxAttempts <= 0 || c.LoginMaxFailures <= 0 || c.LoginIPMaxFailures <= 0 {
+		return errors.New("attempt and failure limits must be positive")
+	}
+	if c.LoginLockBase <= 0 || c.LoginLockMax < c.LoginLockBase {
+		return errors.New("LOGIN_LOCK_MAX must be at least LOGIN_LOCK_BASE")
+	}
+	if c.AnonymizeInterval <= 0 {
+		return errors.New("ANONYMIZE_INTERVAL must be positive")
 	}
+	return nil
 }
 
 func InitRedis(redisURL string) *redis.Client {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+		logging.Fatal("Failed to parse Redis URL", "err", err)
 	}
 	client := redis.NewClient(opt)
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(tracing.RedisHook{})
 	if err := client.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Could not connect to Redis: %v", err)
+		logging.Fatal("Could not connect to Redis", "err", err)
 	}
 
 	return client
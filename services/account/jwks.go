@@ -0,0 +1,250 @@
+package account
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/theshubhamy/microGo/pkg/auth"
+)
+
+// JWKS is a JSON Web Key Set as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func newJWK(kid, alg string, key crypto.PublicKey) JWK {
+	jwk := JWK{Kid: kid, Use: "sig", Alg: alg}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	}
+	return jwk
+}
+
+func (k JWK) publicKey() (crypto.PublicKey, jwt.SigningMethod, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, jwt.SigningMethodRS256, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// JWKSHandler serves the public keys of the set.
+func JWKSHandler(keys *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(keys.JWKS())
+	})
+}
+
+type verificationKey struct {
+	key    crypto.PublicKey
+	method jwt.SigningMethod
+}
+
+// JWKSVerifier verifies access tokens against keys fetched from the account
+// service's JWKS endpoint. Keys are cached and refetched periodically, or
+// early when a token names an unknown kid. Only one fetch runs at a time and
+// failed fetches back off, so an unreachable endpoint doesn't stall every
+// request.
+type JWKSVerifier struct {
+	url        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefetch time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]verificationKey
+	fetchedAt time.Time
+	// Set while a fetch is in flight and closed when it finishes
+	refreshing chan struct{}
+	failures   int
+	retryAt    time.Time
+}
+
+// Backoff after failed JWKS fetches, doubling up to the max
+const (
+	jwksBackoffBase = time.Second
+	jwksBackoffMax  = 5 * time.Minute
+)
+
+func NewJWKSVerifier(url string, refresh time.Duration) *JWKSVerifier {
+	return &JWKSVerifier{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		refresh:    refresh,
+		minRefetch: 30 * time.Second,
+		keys:       map[string]verificationKey{},
+	}
+}
+
+// Verify checks the token signature and expiry and returns its claims.
+func (v *JWKSVerifier) Verify(ctx context.Context, tokenString string) (*CustomClaims, error) {
+	claims := &CustomClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// VerifyAccessToken adapts Verify for the auth interceptors.
+func (v *JWKSVerifier) VerifyAccessToken(ctx context.Context, tokenString string) (*auth.Claims, error) {
+	claims, err := v.Verify(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Claims{UserID: claims.UserID, SessionID: claims.SessionID, Roles: claims.Roles}, nil
+}
+
+// key returns the key named kid. A stale set is refreshed in the background
+// while its keys stay in use; only an unknown kid waits for the fetch.
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (verificationKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	now := time.Now()
+	due := now.Sub(v.fetchedAt) > v.refresh || (!ok && now.Sub(v.fetchedAt) > v.minRefetch)
+	backingOff := now.Before(v.retryAt)
+	v.mu.RUnlock()
+
+	if due && !backingOff {
+		done := v.refreshKeys(ctx)
+		if !ok {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return verificationKey{}, ctx.Err()
+			}
+			v.mu.RLock()
+			key, ok = v.keys[kid]
+			v.mu.RUnlock()
+		}
+	}
+	if !ok {
+		return verificationKey{}, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// refreshKeys starts fetching the key set unless a fetch is already in
+// flight, and returns a channel closed once that fetch has finished. The
+// fetch outlives ctx so that a cancelled request doesn't fail the others
+// waiting on it.
+func (v *JWKSVerifier) refreshKeys(ctx context.Context) <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.refreshing != nil {
+		return v.refreshing
+	}
+	done := make(chan struct{})
+	v.refreshing = done
+	go func() {
+		defer close(done)
+		keys, err := v.fetch(context.WithoutCancel(ctx))
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.refreshing = nil
+		if err != nil {
+			// Keep using cached keys if the account service is briefly down
+			v.failures++
+			backoff := min(jwksBackoffBase<<min(v.failures-1, 20), jwksBackoffMax)
+			v.retryAt = time.Now().Add(backoff)
+			slog.WarnContext(ctx, "Error fetching JWKS", "err", err, "retry_in", backoff)
+			return
+		}
+		v.keys = keys
+		v.fetchedAt = time.Now()
+		v.failures = 0
+		v.retryAt = time.Time{}
+	}()
+	return done
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) (map[string]verificationKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %s", res.Status)
+	}
+
+	var doc JWKS
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	keys := map[string]verificationKey{}
+	for _, jwk := range doc.Keys {
+		key, method, err := jwk.publicKey()
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping JWK", "kid", jwk.Kid, "err", err)
+			continue
+		}
+		keys[jwk.Kid] = verificationKey{key, method}
+	}
+	return keys, nil
+}
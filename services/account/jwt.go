@@ -1,48 +1,120 @@
 package account
 
 import (
-	"log"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/theshubhamy/microGo/pkg/auth"
 )
 
 type CustomClaims struct {
-	UserID string `json:"userID"`
+	UserID    string   `json:"userID"`
+	SessionID string   `json:"sid"`
+	Roles     []string `json:"roles"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID string) (string, string, error) {
-	claims := CustomClaims{
-		UserID: userID,
+// TokenIssuer signs and verifies the tokens handed out by the account
+// service.
+type TokenIssuer struct {
+	keys          *KeySet
+	refreshSecret []byte
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+}
+
+func NewTokenIssuer(cfg Config, keys *KeySet) *TokenIssuer {
+	return &TokenIssuer{
+		keys:          keys,
+		refreshSecret: []byte(cfg.RefreshJWTSecret),
+		accessTTL:     cfg.AccessTokenTTL,
+		refreshTTL:    cfg.RefreshTokenTTL,
+	}
+}
+
+// GenerateJWT issues an access token signed with the active signing key and a
+// refresh token signed with the refresh secret, both bound to sessionID. The
+// refresh token carries refreshID as its jti so it can be rotated and spent
+// exactly once. Roles are only put in the access token; refreshing re-reads them.
+func (t *TokenIssuer) GenerateJWT(userID, sessionID, refreshID string, roles []string) (string, string, error) {
+	now := time.Now()
+	accessClaims := CustomClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Roles:     roles,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)), // token expires in 1 hour
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.accessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	refreshClaims := CustomClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refreshID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.refreshTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret
-	accessToken, err := token.SignedString([]byte(AppConfig.JWT_SECRET))
+	// Access tokens are verified by other services through the JWKS, refresh
+	// tokens never leave this service so they keep a shared secret
+	accessToken, err := t.keys.Sign(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err := token.SignedString([]byte(AppConfig.REFRESH_JWT_SECRET))
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(t.refreshSecret)
 	if err != nil {
 		return "", "", err
 	}
 	return accessToken, refreshToken, nil
 }
 
-func VerifyJWT(tokenString string) (*CustomClaims, error) {
+// VerifyJWT checks an access token against the local key set.
+func (t *TokenIssuer) VerifyJWT(tokenString string) (*CustomClaims, error) {
+	claims := &CustomClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, method, ok := t.keys.PublicKey(kid)
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// VerifyAccessToken adapts VerifyJWT for the auth interceptors.
+func (t *TokenIssuer) VerifyAccessToken(ctx context.Context, tokenString string) (*auth.Claims, error) {
+	claims, err := t.VerifyJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Claims{UserID: claims.UserID, SessionID: claims.SessionID, Roles: claims.Roles}, nil
+}
+
+// VerifyRefreshJWT checks a refresh token against the refresh secret.
+func (t *TokenIssuer) VerifyRefreshJWT(tokenString string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(AppConfig.JWT_SECRET), nil
-	})
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		log.Println(claims)
-		return claims, nil
-	} else {
+		return t.refreshSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
 		return nil, err
 	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.ID == "" || claims.SessionID == "" {
+		return nil, errors.New("refresh token has no id")
+	}
+	return claims, nil
 }
@@ -0,0 +1,131 @@
+package account
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a private key used to sign access tokens, identified by the
+// kid header.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Key    crypto.Signer
+}
+
+// KeySet holds every key that may have signed a live access token. Only the
+// active key signs new tokens; the others stay published until removed so
+// tokens they signed keep verifying during a rotation.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// LoadSigningKeys reads PKCS#8 RSA or Ed25519 private keys named <kid>.pem
+// from dir. activeKID picks the signing key; by default the last kid in
+// lexical order signs, so naming keys by date rotates them. With no dir an
+// ephemeral key is generated, which is only suitable for local runs.
+func LoadSigningKeys(dir, activeKID string) (*KeySet, error) {
+	if dir == "" {
+		slog.Warn("JWT_KEY_DIR not set, generating an ephemeral signing key")
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key := &SigningKey{ID: "ephemeral", Method: jwt.SigningMethodEdDSA, Key: priv}
+		return &KeySet{active: key, keys: map[string]*SigningKey{key.ID: key}}, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	for _, path := range paths {
+		key, err := readSigningKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		set.keys[key.ID] = key
+		set.active = key
+	}
+	if activeKID != "" {
+		set.active = set.keys[activeKID]
+	}
+	if set.active == nil {
+		return nil, errors.New("no active signing key found in " + dir)
+	}
+	return set, nil
+}
+
+func readSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Key: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Key: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.Method, claims)
+	token.Header["kid"] = s.active.ID
+	return token.SignedString(s.active.Key)
+}
+
+// PublicKey returns the verification key and method for kid.
+func (s *KeySet) PublicKey(kid string) (crypto.PublicKey, jwt.SigningMethod, bool) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, nil, false
+	}
+	return key.Key.Public(), key.Method, true
+}
+
+// JWKS returns the public half of every key in the set.
+func (s *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	doc := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := s.keys[id]
+		doc.Keys = append(doc.Keys, newJWK(id, key.Method.Alg(), key.Key.Public()))
+	}
+	return doc
+}
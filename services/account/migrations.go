@@ -0,0 +1,149 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"log/slog"
+	"strings"
+
+	"github.com/theshubhamy/microGo/pkg/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// NewMigrator connects to the account database to apply the schema migrations
+// embedded in the binary. Contacts stored before normalization are rewritten
+// with contacts.
+func NewMigrator(url string, contacts *ContactNormalizer) (*migrate.Migrator, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	m, err := migrate.New(db, "account", migrations, "migrations")
+	if err == nil {
+		err = m.AddFunc(8, "normalize_contacts", func(ctx context.Context, tx *sql.Tx) error {
+			return normalizeContacts(ctx, tx, contacts)
+		})
+	}
+	if err != nil {
+		db.Close()
+	}
+	return m, err
+}
+
+// storedContact is an email or phone as stored on an account. Fixed
+// contacts are placeholders that must stay as they are.
+type storedContact struct {
+	accountID string
+	field     string
+	value     string
+	fixed     bool
+}
+
+// contactReview is a stored contact normalization left alone.
+type contactReview struct {
+	storedContact
+	reason        string
+	conflictsWith string
+}
+
+// planContactNormalization works out which stored contacts to rewrite in
+// normalized form, given in priority order: earlier accounts win a contact
+// they would share once normalized. A contact stays put when it is already
+// normalized, is invalid, or normalizes to a contact another account holds
+// or wins; the latter two are returned for review. normalize maps a field
+// and value to the normalized value.
+func planContactNormalization(contacts []storedContact, normalize func(field, value string) (string, error)) (rewrites []storedContact, reviews []contactReview) {
+	normalized := make([]string, len(contacts))
+	holders := map[string]string{}
+	key := func(field, value string) string { return field + "\x00" + value }
+
+	// Contacts that stay as they are keep their value, so they hold it first
+	stays := make([]bool, len(contacts))
+	for i, c := range contacts {
+		if !c.fixed {
+			value, err := normalize(c.field, c.value)
+			if err != nil {
+				reviews = append(reviews, contactReview{storedContact: c, reason: "invalid"})
+			}
+			normalized[i] = value
+			stays[i] = err != nil || value == c.value
+		}
+		if c.fixed || stays[i] {
+			stays[i] = true
+			holders[key(c.field, c.value)] = c.accountID
+		}
+	}
+	for i, c := range contacts {
+		if stays[i] {
+			continue
+		}
+		if holder, ok := holders[key(c.field, normalized[i])]; ok {
+			reviews = append(reviews, contactReview{storedContact: c, reason: "duplicate", conflictsWith: holder})
+			continue
+		}
+		holders[key(c.field, normalized[i])] = c.accountID
+		rewrites = append(rewrites, storedContact{accountID: c.accountID, field: c.field, value: normalized[i]})
+	}
+	return rewrites, reviews
+}
+
+// normalizeContacts rewrites the contacts of accounts created before
+// normalization, live accounts before deleted ones and older before newer.
+// Anonymized accounts hold placeholders, which are only kept from being
+// taken. Contacts that can't be rewritten go to contact_reviews.
+func normalizeContacts(ctx context.Context, tx *sql.Tx, n *ContactNormalizer) error {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, email, phone, anonymized_at IS NOT NULL FROM accounts ORDER BY deleted_at IS NOT NULL, created_at, id FOR UPDATE",
+	)
+	if err != nil {
+		return err
+	}
+	contacts := []storedContact{}
+	for rows.Next() {
+		var id, email, phone string
+		var anonymized bool
+		if err := rows.Scan(&id, &email, &phone, &anonymized); err != nil {
+			rows.Close()
+			return err
+		}
+		id = strings.TrimSpace(id)
+		contacts = append(contacts,
+			storedContact{accountID: id, field: "email", value: email, fixed: anonymized},
+			storedContact{accountID: id, field: "phone", value: phone, fixed: anonymized},
+		)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rewrites, reviews := planContactNormalization(contacts, func(field, value string) (string, error) {
+		if field == "email" {
+			return n.Email(value)
+		}
+		return n.Phone(value)
+	})
+	for _, c := range rewrites {
+		// field is one of two column names, never user input
+		if _, err := tx.ExecContext(ctx, "UPDATE accounts SET "+c.field+" = $2, updated_at = now() WHERE id = $1", c.accountID, c.value); err != nil {
+			return err
+		}
+	}
+	for _, r := range reviews {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO contact_reviews(account_id, field, reason, conflicts_with) VALUES ($1, $2, $3, NULLIF($4, ''))",
+			r.accountID, r.field, r.reason, r.conflictsWith,
+		); err != nil {
+			return err
+		}
+	}
+	slog.InfoContext(ctx, "Normalized contacts", "rewritten", len(rewrites), "for_review", len(reviews))
+	return nil
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS wallet_transactions;
+DROP TABLE IF EXISTS wallets;
+DROP FUNCTION IF EXISTS reject_ledger_change();
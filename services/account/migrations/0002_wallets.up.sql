@@ -0,0 +1,43 @@
+CREATE TABLE wallets (
+  id CHAR(27) PRIMARY KEY,
+  account_id CHAR(27) NOT NULL UNIQUE REFERENCES accounts (id),
+  currency CHAR(3) NOT NULL DEFAULT 'INR',
+  created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Amounts are in minor units (paise). A wallet's balance is never stored,
+-- it is the sum of its ledger entries.
+CREATE TABLE wallet_transactions (
+  id CHAR(27) PRIMARY KEY,
+  wallet_id CHAR(27) NOT NULL REFERENCES wallets (id),
+  kind VARCHAR(16) NOT NULL CHECK (kind IN ('topup', 'debit')),
+  amount BIGINT NOT NULL CHECK (amount > 0),
+  idempotency_key VARCHAR(255),
+  reference VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (wallet_id, idempotency_key)
+);
+
+-- Double-entry ledger: every transaction writes one entry on the wallet and
+-- an opposite entry on a system account, so all entries sum to zero.
+CREATE TABLE ledger_entries (
+  id BIGSERIAL PRIMARY KEY,
+  transaction_id CHAR(27) NOT NULL REFERENCES wallet_transactions (id),
+  ledger_account VARCHAR(64) NOT NULL,
+  amount BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ledger_entries_account ON ledger_entries (ledger_account);
+
+CREATE OR REPLACE FUNCTION reject_ledger_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_transactions_immutable BEFORE UPDATE OR DELETE ON wallet_transactions
+  FOR EACH ROW EXECUTE PROCEDURE reject_ledger_change();
+
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+  FOR EACH ROW EXECUTE PROCEDURE reject_ledger_change();
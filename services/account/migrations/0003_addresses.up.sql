@@ -0,0 +1,19 @@
+CREATE TABLE addresses (
+  id CHAR(27) PRIMARY KEY,
+  account_id CHAR(27) NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+  label VARCHAR(64) NOT NULL,
+  line1 VARCHAR(255) NOT NULL,
+  line2 VARCHAR(255) NOT NULL DEFAULT '',
+  landmark VARCHAR(255) NOT NULL DEFAULT '',
+  city VARCHAR(128) NOT NULL,
+  state VARCHAR(128) NOT NULL,
+  pincode VARCHAR(6) NOT NULL,
+  latitude DOUBLE PRECISION NOT NULL,
+  longitude DOUBLE PRECISION NOT NULL,
+  is_default BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMPTZ DEFAULT now(),
+  updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_addresses_account ON addresses (account_id);
+CREATE UNIQUE INDEX idx_addresses_one_default ON addresses (account_id) WHERE is_default;
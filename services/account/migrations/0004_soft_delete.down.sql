@@ -0,0 +1,3 @@
+ALTER TABLE accounts
+  DROP COLUMN IF EXISTS anonymized_at,
+  DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+-- Deleted accounts are kept for a grace period, then stripped of PII
+ALTER TABLE accounts
+  ADD COLUMN deleted_at TIMESTAMPTZ,
+  ADD COLUMN anonymized_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+ALTER TABLE accounts
+  DROP COLUMN IF EXISTS phone_verified_at,
+  DROP COLUMN IF EXISTS email_verified_at;
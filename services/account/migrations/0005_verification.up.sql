@@ -0,0 +1,3 @@
+ALTER TABLE accounts
+  ADD COLUMN email_verified_at TIMESTAMPTZ,
+  ADD COLUMN phone_verified_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+-- Existing accounts become customers
+ALTER TABLE accounts ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{customer}';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounts_phone_trgm;
+DROP INDEX IF EXISTS idx_accounts_email_trgm;
+DROP INDEX IF EXISTS idx_accounts_name_trgm;
+DROP INDEX IF EXISTS idx_accounts_created_at;
+ALTER TABLE accounts ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,10 @@
+-- Keyset pagination orders by created_at, so it can't be missing
+UPDATE accounts SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE accounts ALTER COLUMN created_at SET NOT NULL;
+CREATE INDEX idx_accounts_created_at ON accounts (created_at DESC, id DESC);
+
+-- Trigram indexes back the partial name/email/phone search in ListAccounts
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_accounts_name_trgm ON accounts USING gin (name gin_trgm_ops);
+CREATE INDEX idx_accounts_email_trgm ON accounts USING gin (email gin_trgm_ops);
+CREATE INDEX idx_accounts_phone_trgm ON accounts USING gin (phone gin_trgm_ops);
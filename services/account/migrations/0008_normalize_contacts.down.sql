@@ -0,0 +1,2 @@
+-- Normalized contacts stay as they are
+DROP TABLE IF EXISTS contact_reviews;
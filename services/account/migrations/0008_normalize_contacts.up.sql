@@ -0,0 +1,13 @@
+-- Contacts stored before signups normalized them are rewritten by a Go step
+-- of this migration. Those it can't rewrite, because they are invalid or
+-- would take another account's contact, are kept as they are and listed
+-- here for support to resolve.
+CREATE TABLE contact_reviews (
+  account_id CHAR(27) NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+  field VARCHAR(8) NOT NULL CHECK (field IN ('email', 'phone')),
+  reason VARCHAR(16) NOT NULL CHECK (reason IN ('invalid', 'duplicate')),
+  -- The account already holding the normalized contact, for duplicates
+  conflicts_with CHAR(27) REFERENCES accounts (id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (account_id, field)
+);
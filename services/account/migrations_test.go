@@ -0,0 +1,114 @@
+package account
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanContactNormalization(t *testing.T) {
+	n, err := NewContactNormalizer("IN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalize := func(field, value string) (string, error) {
+		if field == "email" {
+			return n.Email(value)
+		}
+		return n.Phone(value)
+	}
+
+	tests := []struct {
+		name     string
+		contacts []storedContact
+		rewrites []storedContact
+		reviews  []contactReview
+	}{
+		{
+			name: "already normalized",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: "a@example.com"},
+				{accountID: "a", field: "phone", value: "+919876543210"},
+			},
+		},
+		{
+			name: "rewritten",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: " A@Example.com"},
+				{accountID: "a", field: "phone", value: "098765 43210"},
+			},
+			rewrites: []storedContact{
+				{accountID: "a", field: "email", value: "a@example.com"},
+				{accountID: "a", field: "phone", value: "+919876543210"},
+			},
+		},
+		{
+			name: "earlier account wins a shared contact",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: "A@example.com"},
+				{accountID: "b", field: "email", value: "a@EXAMPLE.com"},
+			},
+			rewrites: []storedContact{
+				{accountID: "a", field: "email", value: "a@example.com"},
+			},
+			reviews: []contactReview{
+				{storedContact: storedContact{accountID: "b", field: "email", value: "a@EXAMPLE.com"}, reason: "duplicate", conflictsWith: "a"},
+			},
+		},
+		{
+			name: "contact already held by a later account stays with it",
+			contacts: []storedContact{
+				{accountID: "a", field: "phone", value: "9876543210"},
+				{accountID: "b", field: "phone", value: "+919876543210"},
+			},
+			reviews: []contactReview{
+				{storedContact: storedContact{accountID: "a", field: "phone", value: "9876543210"}, reason: "duplicate", conflictsWith: "b"},
+			},
+		},
+		{
+			name: "invalid field is flagged and the other still rewritten",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: "not an email"},
+				{accountID: "a", field: "phone", value: "09876543210"},
+			},
+			rewrites: []storedContact{
+				{accountID: "a", field: "phone", value: "+919876543210"},
+			},
+			reviews: []contactReview{
+				{storedContact: storedContact{accountID: "a", field: "email", value: "not an email"}, reason: "invalid"},
+			},
+		},
+		{
+			name: "account with an invalid field keeps its valid contact",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: "not an email"},
+				{accountID: "a", field: "phone", value: "+919876543210"},
+				{accountID: "b", field: "phone", value: "9876543210"},
+			},
+			reviews: []contactReview{
+				{storedContact: storedContact{accountID: "a", field: "email", value: "not an email"}, reason: "invalid"},
+				{storedContact: storedContact{accountID: "b", field: "phone", value: "9876543210"}, reason: "duplicate", conflictsWith: "a"},
+			},
+		},
+		{
+			name: "anonymized placeholders are held but not reviewed",
+			contacts: []storedContact{
+				{accountID: "a", field: "email", value: "anonymized+a@invalid", fixed: true},
+				{accountID: "b", field: "email", value: "anonymized+a@INVALID.com"},
+			},
+			rewrites: []storedContact{
+				{accountID: "b", field: "email", value: "anonymized+a@invalid.com"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrites, reviews := planContactNormalization(tt.contacts, normalize)
+			if !reflect.DeepEqual(rewrites, tt.rewrites) {
+				t.Errorf("rewrites = %+v, want %+v", rewrites, tt.rewrites)
+			}
+			if !reflect.DeepEqual(reviews, tt.reviews) {
+				t.Errorf("reviews = %+v, want %+v", reviews, tt.reviews)
+			}
+		})
+	}
+}
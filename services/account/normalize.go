@@ -0,0 +1,111 @@
+package account
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+var (
+	ErrInvalidEmail = errs.InvalidArgument("email", "invalid email")
+	ErrInvalidPhone = errs.InvalidArgument("phone", "invalid phone")
+)
+
+// phoneRegion describes how numbers are dialled nationally in a region, so
+// they can be rewritten in E.164 form.
+type phoneRegion struct {
+	countryCode    string
+	nationalDigits int
+	trunkPrefix    string
+}
+
+var phoneRegions = map[string]phoneRegion{
+	"IN": {countryCode: "91", nationalDigits: 10, trunkPrefix: "0"},
+	"US": {countryCode: "1", nationalDigits: 10},
+	"CA": {countryCode: "1", nationalDigits: 10},
+	"GB": {countryCode: "44", nationalDigits: 10, trunkPrefix: "0"},
+	"AE": {countryCode: "971", nationalDigits: 9, trunkPrefix: "0"},
+	"SG": {countryCode: "65", nationalDigits: 8},
+	"AU": {countryCode: "61", nationalDigits: 9, trunkPrefix: "0"},
+}
+
+// ContactNormalizer canonicalizes emails and phone numbers so the same
+// contact always maps to the same stored value.
+type ContactNormalizer struct {
+	region phoneRegion
+}
+
+// NewContactNormalizer reads numbers without a country code as belonging to
+// defaultRegion, an ISO 3166 code such as "IN".
+func NewContactNormalizer(defaultRegion string) (*ContactNormalizer, error) {
+	region, ok := phoneRegions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported phone region %q", defaultRegion)
+	}
+	return &ContactNormalizer{region: region}, nil
+}
+
+// Email trims and case-folds the address.
+func (n *ContactNormalizer) Email(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// Phone rewrites the number in E.164 form, e.g. "098765 43210" with region
+// IN becomes "+919876543210".
+func (n *ContactNormalizer) Phone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+	digits := strings.Builder{}
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case international:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case len(number) == n.region.nationalDigits:
+		number = n.region.countryCode + number
+	case n.region.trunkPrefix != "" && len(number) == len(n.region.trunkPrefix)+n.region.nationalDigits &&
+		strings.HasPrefix(number, n.region.trunkPrefix):
+		number = n.region.countryCode + number[len(n.region.trunkPrefix):]
+	case len(number) == len(n.region.countryCode)+n.region.nationalDigits && strings.HasPrefix(number, n.region.countryCode):
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	// E.164 allows at most 15 digits and no leading zero
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
+
+// Identifier normalizes a login identifier and reports whether it is an
+// "email" or a "phone", matching the accounts column to look it up by.
+func (n *ContactNormalizer) Identifier(identifier string) (string, string, error) {
+	if strings.Contains(identifier, "@") {
+		email, err := n.Email(identifier)
+		return "email", email, err
+	}
+	phone, err := n.Phone(identifier)
+	if err != nil {
+		return "", "", errs.InvalidArgument("emailorphone", "input must be a valid email or phone number")
+	}
+	return "phone", phone, nil
+}
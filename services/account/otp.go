@@ -0,0 +1,163 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	ErrOTPInvalid         = errs.Unauthenticated("invalid or expired code")
+	ErrOTPTooManyAttempts = errs.New(codes.ResourceExhausted, "too many attempts, request a new code")
+	ErrOTPCooldown        = errs.New(codes.ResourceExhausted, "code already sent, try again later")
+)
+
+// OTPSender delivers one-time codes to a phone number or email address.
+type OTPSender interface {
+	SendOTP(ctx context.Context, channel, identifier, code string) error
+}
+
+type logOTPSender struct {
+	mu        sync.Mutex
+	path      string
+	plaintext bool
+}
+
+// NewLogOTPSender returns an OTPSender for local use that delivers nothing.
+// It logs the channel and the masked identifier a code went to, never the
+// code itself. With plaintext, which is only meant for development, codes
+// are appended to the file at path instead, or written to stderr when path
+// is empty. They bypass the logger, whose redaction would hide them.
+func NewLogOTPSender(path string, plaintext bool) OTPSender {
+	return &logOTPSender{path: path, plaintext: plaintext}
+}
+
+func (s *logOTPSender) SendOTP(ctx context.Context, channel, identifier, code string) error {
+	if !s.plaintext {
+		slog.InfoContext(ctx, "OTP issued", "channel", channel, "identifier", identifier)
+		return nil
+	}
+
+	line := fmt.Sprintf("%s otp %s %s: %s\n", time.Now().UTC().Format(time.RFC3339), channel, identifier, code)
+	if s.path == "" {
+		_, err := fmt.Fprint(os.Stderr, line)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line)
+	return err
+}
+
+func (as *accountService) RequestOTP(ctx context.Context, identifier string) error {
+	channel, identifier, err := as.contacts.Identifier(identifier)
+	if err != nil {
+		return err
+	}
+
+	cooldownKey := "otp-cooldown:" + identifier
+	ok, err := as.redisClient.SetNX(ctx, cooldownKey, 1, as.config.OTPResendCooldown).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check otp cooldown: %w", err)
+	}
+	if !ok {
+		return ErrOTPCooldown
+	}
+
+	// Don't reveal whether an account exists for the identifier
+	if _, err := as.repository.GetAccount(ctx, channel, identifier); err != nil {
+		slog.InfoContext(ctx, "OTP requested for unknown account", "channel", channel, "identifier", identifier, "err", err)
+		return nil
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		return err
+	}
+
+	otpKey := "otp:" + identifier
+	pipe := as.redisClient.TxPipeline()
+	pipe.HSet(ctx, otpKey, "hash", hashOTP(identifier, code), "attempts", 0)
+	pipe.Expire(ctx, otpKey, as.config.OTPTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store otp: %w", err)
+	}
+
+	return as.otpSender.SendOTP(ctx, channel, identifier, code)
+}
+
+func (as *accountService) VerifyOTP(ctx context.Context, identifier, code, ip, userAgent string) (*Account, string, string, error) {
+	channel, identifier, err := as.contacts.Identifier(identifier)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	otpKey := "otp:" + identifier
+	attempts, err := as.redisClient.HIncrBy(ctx, otpKey, "attempts", 1).Result()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read otp: %w", err)
+	}
+	hash, err := as.redisClient.HGet(ctx, otpKey, "hash").Result()
+	if err == redis.Nil {
+		// HIncrBy created a stray key for an unknown identifier
+		as.redisClient.Del(ctx, otpKey)
+		return nil, "", "", ErrOTPInvalid
+	} else if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read otp: %w", err)
+	}
+	if attempts > int64(as.config.OTPMaxAttempts) {
+		as.redisClient.Del(ctx, otpKey)
+		return nil, "", "", ErrOTPTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashOTP(identifier, code))) != 1 {
+		return nil, "", "", ErrOTPInvalid
+	}
+
+	// Codes are single use; losing the race means someone else spent it
+	deleted, err := as.redisClient.Del(ctx, otpKey).Result()
+	if err != nil || deleted == 0 {
+		return nil, "", "", ErrOTPInvalid
+	}
+
+	account, err := as.repository.GetAccount(ctx, channel, identifier)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	accessToken, refreshToken, err := as.createSession(ctx, account, ip, userAgent)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return account, accessToken, refreshToken, nil
+}
+
+func generateOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashOTP(identifier, code string) string {
+	hash := sha256.Sum256([]byte(identifier + ":" + code))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,80 @@
+package account
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would give a
+// false sense of strength.
+const passwordMaxBytes = 72
+
+var sha1HexRegex = regexp.MustCompile(`^[0-9A-Fa-f]{40}$`)
+
+// PasswordPolicy decides which passwords may be set on signup or change.
+type PasswordPolicy struct {
+	MinLength int
+	// SHA-1 hex digests (upper case) of known breached passwords
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy loads the breached password list from path, if set. Each
+// line is either a plain password or an upper or lower case SHA-1 hex digest,
+// optionally followed by ":count" as in the Have I Been Pwned dumps.
+func NewPasswordPolicy(minLength int, path string) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{MinLength: minLength, breached: map[string]struct{}{}}
+	if path == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if digest, _, _ := strings.Cut(line, ":"); sha1HexRegex.MatchString(digest) {
+			p.breached[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+		p.breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Check returns an InvalidArgument error for field when password breaks the
+// policy.
+func (p *PasswordPolicy) Check(field, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return errs.InvalidArgument(field, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if len(password) > passwordMaxBytes {
+		return errs.InvalidArgument(field, fmt.Sprintf("password must be at most %d bytes", passwordMaxBytes))
+	}
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return errs.InvalidArgument(field, "password appears in a known data breach, choose another one")
+	}
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
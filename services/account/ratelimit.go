@@ -0,0 +1,150 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// LoginThrottledError is returned when an identifier is locked out or an IP
+// has failed too many logins. It carries a retry-after hint and converts to
+// a gRPC status with RetryInfo details.
+type LoginThrottledError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account temporarily locked, retry after %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// GRPCStatus lets grpc-go send the error with its code and RetryInfo.
+func (e *LoginThrottledError) GRPCStatus() *status.Status {
+	code := codes.ResourceExhausted
+	if e.Locked {
+		code = codes.PermissionDenied
+	}
+	st := status.New(code, e.Error())
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// checkLoginAllowed rejects logins for locked identifiers and for IPs that
+// exceeded their failure budget in the sliding window.
+func (as *accountService) checkLoginAllowed(ctx context.Context, identifier, ip string) error {
+	ttl, err := as.redisClient.PTTL(ctx, "login-lock:"+identifier).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check login lock: %w", err)
+	}
+	if ttl > 0 {
+		return &LoginThrottledError{Locked: true, RetryAfter: ttl}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	ipKey := "login-failures:ip:" + ip
+	count, oldest, err := as.failuresInWindow(ctx, ipKey)
+	if err != nil {
+		return err
+	}
+	if count >= int64(as.config.LoginIPMaxFailures) {
+		return &LoginThrottledError{RetryAfter: time.Until(oldest.Add(as.config.LoginFailureWindow))}
+	}
+	return nil
+}
+
+// recordLoginFailure adds a failure for the identifier and the IP, locking
+// the identifier with exponential backoff once it reaches the limit.
+func (as *accountService) recordLoginFailure(ctx context.Context, identifier, ip string) {
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 10)
+	idKey := "login-failures:id:" + identifier
+
+	keys := []string{idKey}
+	if ip != "" {
+		keys = append(keys, "login-failures:ip:"+ip)
+	}
+
+	pipe := as.redisClient.TxPipeline()
+	for _, key := range keys {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: member})
+		pipe.Expire(ctx, key, as.config.LoginFailureWindow)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "err", err)
+		return
+	}
+
+	count, _, err := as.failuresInWindow(ctx, idKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count login failures", "err", err)
+		return
+	}
+	if count < int64(as.config.LoginMaxFailures) {
+		return
+	}
+
+	// Each lockout within a day doubles the next one
+	lockouts, err := as.redisClient.Incr(ctx, "login-lock-count:"+identifier).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count lockouts", "err", err)
+		return
+	}
+	as.redisClient.Expire(ctx, "login-lock-count:"+identifier, 24*time.Hour)
+
+	lock := as.config.LoginLockBase
+	for i := int64(1); i < lockouts && lock < as.config.LoginLockMax; i++ {
+		lock *= 2
+	}
+	lock = min(lock, as.config.LoginLockMax)
+
+	as.redisClient.Set(ctx, "login-lock:"+identifier, lockouts, lock)
+	as.redisClient.Del(ctx, idKey)
+}
+
+// resetLoginFailures clears the identifier's failure history after a
+// successful login.
+func (as *accountService) resetLoginFailures(ctx context.Context, identifier string) {
+	as.redisClient.Del(ctx, "login-failures:id:"+identifier, "login-lock-count:"+identifier)
+}
+
+// failuresInWindow trims the sliding window and returns the remaining count
+// and the time of the oldest failure still in it.
+func (as *accountService) failuresInWindow(ctx context.Context, key string) (int64, time.Time, error) {
+	windowStart := time.Now().Add(-as.config.LoginFailureWindow).UnixNano()
+	pipe := as.redisClient.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(windowStart, 10))
+	count := pipe.ZCard(ctx, key)
+	oldest := pipe.ZRangeWithScores(ctx, key, 0, 0)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read login failures: %w", err)
+	}
+
+	var oldestAt time.Time
+	if entries := oldest.Val(); len(entries) > 0 {
+		oldestAt = time.Unix(0, int64(entries[0].Score))
+	}
+	return count.Val(), oldestAt, nil
+}
+
+// isLoginFailure reports whether err from a login attempt should count
+// against the failure budget.
+func isLoginFailure(err error) bool {
+	return errors.Is(err, ErrAccountNotFound) || errors.Is(err, ErrInvalidCredentials)
+}
@@ -3,24 +3,50 @@ package account
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 )
 
 type Account struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Phone    string `josn:"phone"`
-	Password string `json:"password"`
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Phone         string    `josn:"phone"`
+	Password      string    `json:"password"`
+	EmailVerified bool      `json:"emailVerified"`
+	PhoneVerified bool      `json:"phoneVerified"`
+	Roles         []string  `json:"roles"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Deleted       bool      `json:"deleted"`
 }
 
 type Repository interface {
 	Close() error
+	Ping(ctx context.Context) error
 	PutAccount(ctx context.Context, acc Account) error
 	GetAccount(ctx context.Context, key, value string) (*Account, error)
-	ListAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error)
+	ListAccounts(ctx context.Context, filter AccountFilter, after *AccountCursor, skip uint64, take uint64) ([]Account, error)
+	UpdateAccount(ctx context.Context, acc Account) (*Account, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	SoftDeleteAccount(ctx context.Context, id string) error
+	AnonymizeDeletedAccounts(ctx context.Context, deletedBefore time.Time) (int64, error)
+	SetAccountRoles(ctx context.Context, id string, roles []string) error
+	MarkEmailVerified(ctx context.Context, id, email string) error
+	MarkPhoneVerified(ctx context.Context, id, phone string) error
+	GetOrCreateWallet(ctx context.Context, accountID, newWalletID string) (*Wallet, error)
+	GetWalletBalance(ctx context.Context, walletID string) (int64, error)
+	PostWalletTransaction(ctx context.Context, t WalletTransaction) (*WalletTransaction, error)
+	ListWalletTransactions(ctx context.Context, walletID string, skip uint64, take uint64) ([]WalletTransaction, error)
+	PutAddress(ctx context.Context, a Address) error
+	ListAddresses(ctx context.Context, accountID string) ([]Address, error)
+	UpdateAddress(ctx context.Context, a Address) (*Address, error)
+	DeleteAddress(ctx context.Context, accountID, addressID string) error
+	SetDefaultAddress(ctx context.Context, accountID, addressID string) (*Address, error)
 }
 
 type postgresRepository struct {
@@ -43,33 +69,104 @@ func (r *postgresRepository) Close() error {
 	return r.db.Close()
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
-func (r *postgresRepository) PutAccount(ctx context.Context, acc Account) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO accounts(id,name,email,phone,password) VALUES($1,$2,$3,$4,$5)", acc.ID, acc.Name, acc.Email, acc.Phone, acc.Password)
+func (r *postgresRepository) PutAccount(ctx context.Context, acc Account) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "PutAccount")
+	defer func() { done(err) }()
+	_, err = r.db.ExecContext(ctx, "INSERT INTO accounts(id,name,email,phone,password) VALUES($1,$2,$3,$4,$5)", acc.ID, acc.Name, acc.Email, acc.Phone, acc.Password)
+	return uniqueViolation(err)
+}
+
+// uniqueViolation turns a unique constraint violation on email or phone into
+// ErrEmailTaken or ErrPhoneTaken. Other errors are returned unchanged.
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+	switch {
+	case strings.Contains(pqErr.Constraint, "email"):
+		return ErrEmailTaken
+	case strings.Contains(pqErr.Constraint, "phone"):
+		return ErrPhoneTaken
+	}
 	return err
 }
 
-func (r *postgresRepository) GetAccount(ctx context.Context, key, value string) (*Account, error) {
+func (r *postgresRepository) GetAccount(ctx context.Context, key, value string) (_ *Account, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetAccount")
+	defer func() { done(err) }()
 	if !isAllowedKey(key) {
 		return nil, fmt.Errorf("invalid column key: %s", key)
 	}
 	// construct the query safely since key is validated
-	query := fmt.Sprintf(`SELECT id, name, email, phone,password FROM accounts WHERE %s = $1`, key)
+	query := fmt.Sprintf(`SELECT id, name, email, phone,password, email_verified_at IS NOT NULL, phone_verified_at IS NOT NULL, roles, created_at FROM accounts WHERE %s = $1 AND deleted_at IS NULL`, key)
 
 	row := r.db.QueryRowContext(ctx, query, value)
 	a := &Account{}
-	err := row.Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.Password)
+	err = row.Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.Password, &a.EmailVerified, &a.PhoneVerified, pq.Array(&a.Roles), &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return a, err
 }
 
-func (r *postgresRepository) ListAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id,name,email,phone FROM accounts ORDER BY id DESC OFFSET $1 LIMIT $2", skip, take)
+// ListAccounts returns accounts matching filter, newest first. Pages are
+// keyed on (created_at, id) so concurrent signups don't shift them; skip is
+// applied after the cursor.
+func (r *postgresRepository) ListAccounts(ctx context.Context, filter AccountFilter, after *AccountCursor, skip uint64, take uint64) (_ []Account, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "ListAccounts")
+	defer func() { done(err) }()
+	conds := []string{}
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch filter.Deletion {
+	case DeletionDeleted:
+		conds = append(conds, "deleted_at IS NOT NULL")
+	case DeletionAny:
+	default:
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if filter.Query != "" {
+		// Escape LIKE wildcards so the query is matched literally
+		q := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query) + "%"
+		p := arg(q)
+		conds = append(conds, fmt.Sprintf("(name ILIKE %s OR email ILIKE %s OR phone ILIKE %s)", p, p, p))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conds = append(conds, "created_at >= "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conds = append(conds, "created_at < "+arg(filter.CreatedBefore))
+	}
+	if filter.EmailVerified != nil {
+		conds = append(conds, "(email_verified_at IS NOT NULL) = "+arg(*filter.EmailVerified))
+	}
+	if filter.PhoneVerified != nil {
+		conds = append(conds, "(phone_verified_at IS NOT NULL) = "+arg(*filter.PhoneVerified))
+	}
+	if after != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(after.CreatedAt), arg(after.ID)))
+	}
+
+	query := `SELECT id, name, email, phone, email_verified_at IS NOT NULL, phone_verified_at IS NOT NULL, roles, created_at, deleted_at IS NOT NULL
+		FROM accounts`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC OFFSET %s LIMIT %s", arg(skip), arg(take))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +179,7 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip uint64, take
 
 	for rows.Next() {
 		a := Account{}
-		err = rows.Scan(&a.ID, &a.Name, &a.Email, &a.Phone)
+		err = rows.Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.EmailVerified, &a.PhoneVerified, pq.Array(&a.Roles), &a.CreatedAt, &a.Deleted)
 		if err != nil {
 			return nil, err
 		}
@@ -94,3 +191,387 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip uint64, take
 
 	return accounts, err
 }
+
+func (r *postgresRepository) UpdateAccount(ctx context.Context, acc Account) (_ *Account, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "UpdateAccount")
+	defer func() { done(err) }()
+	a := &Account{}
+	err = r.db.QueryRowContext(ctx,
+		`UPDATE accounts SET name=$2, email=$3, phone=$4, updated_at=now(),
+			email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+			phone_verified_at = CASE WHEN phone = $4 THEN phone_verified_at END
+		WHERE id=$1 AND deleted_at IS NULL
+		RETURNING id, name, email, phone, email_verified_at IS NOT NULL, phone_verified_at IS NOT NULL, roles`,
+		acc.ID, acc.Name, acc.Email, acc.Phone,
+	).Scan(&a.ID, &a.Name, &a.Email, &a.Phone, &a.EmailVerified, &a.PhoneVerified, pq.Array(&a.Roles))
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, uniqueViolation(err)
+	}
+	return a, nil
+}
+
+func (r *postgresRepository) UpdatePassword(ctx context.Context, id, passwordHash string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "UpdatePassword")
+	defer func() { done(err) }()
+	res, err := r.db.ExecContext(ctx, "UPDATE accounts SET password=$2, updated_at=now() WHERE id=$1 AND deleted_at IS NULL", id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository) SetAccountRoles(ctx context.Context, id string, roles []string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "SetAccountRoles")
+	defer func() { done(err) }()
+	res, err := r.db.ExecContext(ctx, "UPDATE accounts SET roles=$2, updated_at=now() WHERE id=$1 AND deleted_at IS NULL", id, pq.Array(roles))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+// MarkEmailVerified only succeeds while the account still has that email, so
+// a token issued for an old address can't verify a new one.
+func (r *postgresRepository) MarkEmailVerified(ctx context.Context, id, email string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "MarkEmailVerified")
+	defer func() { done(err) }()
+	res, err := r.db.ExecContext(ctx, "UPDATE accounts SET email_verified_at=COALESCE(email_verified_at, now()) WHERE id=$1 AND email=$2 AND deleted_at IS NULL", id, email)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository) MarkPhoneVerified(ctx context.Context, id, phone string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "MarkPhoneVerified")
+	defer func() { done(err) }()
+	res, err := r.db.ExecContext(ctx, "UPDATE accounts SET phone_verified_at=COALESCE(phone_verified_at, now()) WHERE id=$1 AND phone=$2 AND deleted_at IS NULL", id, phone)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository) SoftDeleteAccount(ctx context.Context, id string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "SoftDeleteAccount")
+	defer func() { done(err) }()
+	res, err := r.db.ExecContext(ctx, "UPDATE accounts SET deleted_at=now(), updated_at=now() WHERE id=$1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+// AnonymizeDeletedAccounts scrubs PII from accounts soft-deleted before the
+// given time, and deletes their addresses, in one transaction. The row
+// itself stays so orders and ledger entries still resolve.
+func (r *postgresRepository) AnonymizeDeletedAccounts(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "AnonymizeDeletedAccounts")
+	defer func() { done(err) }()
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	rows, err := txn.QueryContext(ctx,
+		`UPDATE accounts SET name='Deleted user', email='deleted+' || trim(id) || '@invalid', phone='deleted-' || trim(id), password='', anonymized_at=now(), updated_at=now()
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND anonymized_at IS NULL
+		RETURNING id`,
+		deletedBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if _, err = txn.ExecContext(ctx, "DELETE FROM addresses WHERE account_id = ANY($1)", pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (r *postgresRepository) GetOrCreateWallet(ctx context.Context, accountID, newWalletID string) (_ *Wallet, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetOrCreateWallet")
+	defer func() { done(err) }()
+	_, err = r.db.ExecContext(ctx, "INSERT INTO wallets(id,account_id) VALUES($1,$2) ON CONFLICT (account_id) DO NOTHING", newWalletID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	w := &Wallet{}
+	err = r.db.QueryRowContext(ctx, "SELECT id, account_id, currency FROM wallets WHERE account_id = $1", accountID).Scan(&w.ID, &w.AccountID, &w.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *postgresRepository) GetWalletBalance(ctx context.Context, walletID string) (_ int64, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetWalletBalance")
+	defer func() { done(err) }()
+	var balance int64
+	err = r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount),0) FROM ledger_entries WHERE ledger_account = $1", walletLedgerAccount(walletID)).Scan(&balance)
+	return balance, err
+}
+
+// PostWalletTransaction records a top-up or debit and its two ledger entries.
+// The wallet row is locked for the duration so concurrent debits are
+// serialized and can never take the balance below zero.
+func (r *postgresRepository) PostWalletTransaction(ctx context.Context, t WalletTransaction) (_ *WalletTransaction, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "PostWalletTransaction")
+	defer func() { done(err) }()
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	var walletID string
+	if err = txn.QueryRowContext(ctx, "SELECT id FROM wallets WHERE id = $1 FOR UPDATE", t.WalletID).Scan(&walletID); err != nil {
+		return nil, err
+	}
+
+	if t.IdempotencyKey != "" {
+		existing := WalletTransaction{}
+		var createdAt time.Time
+		err = txn.QueryRowContext(ctx,
+			"SELECT id, wallet_id, kind, amount, idempotency_key, reference, created_at FROM wallet_transactions WHERE wallet_id = $1 AND idempotency_key = $2",
+			t.WalletID, t.IdempotencyKey,
+		).Scan(&existing.ID, &existing.WalletID, &existing.Kind, &existing.Amount, &existing.IdempotencyKey, &existing.Reference, &createdAt)
+		if err == nil {
+			if existing.Kind != t.Kind || existing.Amount != t.Amount {
+				return nil, ErrIdempotencyConflict
+			}
+			existing.CreatedAt = createdAt.Unix()
+			return &existing, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	walletAmount, counterAccount := t.Amount, "system:topups"
+	if t.Kind == WalletDebit {
+		var balance int64
+		err = txn.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount),0) FROM ledger_entries WHERE ledger_account = $1", walletLedgerAccount(t.WalletID)).Scan(&balance)
+		if err != nil {
+			return nil, err
+		}
+		if balance < t.Amount {
+			return nil, ErrInsufficientFunds
+		}
+		walletAmount, counterAccount = -t.Amount, "system:payments"
+	}
+
+	var createdAt time.Time
+	err = txn.QueryRowContext(ctx,
+		"INSERT INTO wallet_transactions(id,wallet_id,kind,amount,idempotency_key,reference) VALUES($1,$2,$3,$4,NULLIF($5,''),$6) RETURNING created_at",
+		t.ID, t.WalletID, t.Kind, t.Amount, t.IdempotencyKey, t.Reference,
+	).Scan(&createdAt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = txn.ExecContext(ctx,
+		"INSERT INTO ledger_entries(transaction_id,ledger_account,amount) VALUES($1,$2,$3),($1,$4,$5)",
+		t.ID, walletLedgerAccount(t.WalletID), walletAmount, counterAccount, -walletAmount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt = createdAt.Unix()
+	return &t, nil
+}
+
+func (r *postgresRepository) ListWalletTransactions(ctx context.Context, walletID string, skip uint64, take uint64) (_ []WalletTransaction, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "ListWalletTransactions")
+	defer func() { done(err) }()
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, wallet_id, kind, amount, COALESCE(idempotency_key,''), reference, created_at FROM wallet_transactions WHERE wallet_id = $1 ORDER BY created_at DESC, id DESC OFFSET $2 LIMIT $3",
+		walletID, skip, take,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []WalletTransaction{}
+	for rows.Next() {
+		t := WalletTransaction{}
+		var createdAt time.Time
+		if err = rows.Scan(&t.ID, &t.WalletID, &t.Kind, &t.Amount, &t.IdempotencyKey, &t.Reference, &createdAt); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = createdAt.Unix()
+		transactions = append(transactions, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func walletLedgerAccount(walletID string) string {
+	return "wallet:" + walletID
+}
+
+const addressColumns = "id, account_id, label, line1, line2, landmark, city, state, pincode, latitude, longitude, is_default"
+
+func scanAddress(row interface{ Scan(...any) error }) (*Address, error) {
+	a := &Address{}
+	err := row.Scan(&a.ID, &a.AccountID, &a.Label, &a.Line1, &a.Line2, &a.Landmark, &a.City, &a.State, &a.Pincode, &a.Latitude, &a.Longitude, &a.IsDefault)
+	if err == sql.ErrNoRows {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *postgresRepository) PutAddress(ctx context.Context, a Address) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "PutAddress")
+	defer func() { done(err) }()
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO addresses("+addressColumns+") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)",
+		a.ID, a.AccountID, a.Label, a.Line1, a.Line2, a.Landmark, a.City, a.State, a.Pincode, a.Latitude, a.Longitude, a.IsDefault,
+	)
+	return err
+}
+
+func (r *postgresRepository) ListAddresses(ctx context.Context, accountID string) (_ []Address, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "ListAddresses")
+	defer func() { done(err) }()
+	rows, err := r.db.QueryContext(ctx, "SELECT "+addressColumns+" FROM addresses WHERE account_id = $1 ORDER BY is_default DESC, created_at DESC", accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []Address{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// UpdateAddress rewrites an address owned by a.AccountID. The default flag is
+// left alone; use SetDefaultAddress to change it.
+func (r *postgresRepository) UpdateAddress(ctx context.Context, a Address) (_ *Address, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "UpdateAddress")
+	defer func() { done(err) }()
+	row := r.db.QueryRowContext(ctx,
+		`UPDATE addresses SET label=$3, line1=$4, line2=$5, landmark=$6, city=$7, state=$8, pincode=$9, latitude=$10, longitude=$11, updated_at=now()
+		WHERE id=$1 AND account_id=$2 RETURNING `+addressColumns,
+		a.ID, a.AccountID, a.Label, a.Line1, a.Line2, a.Landmark, a.City, a.State, a.Pincode, a.Latitude, a.Longitude,
+	)
+	return scanAddress(row)
+}
+
+// DeleteAddress removes an address owned by accountID. Deleting the default
+// address promotes the most recently created remaining one.
+func (r *postgresRepository) DeleteAddress(ctx context.Context, accountID, addressID string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "DeleteAddress")
+	defer func() { done(err) }()
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	var wasDefault bool
+	err = txn.QueryRowContext(ctx, "DELETE FROM addresses WHERE id=$1 AND account_id=$2 RETURNING is_default", addressID, accountID).Scan(&wasDefault)
+	if err == sql.ErrNoRows {
+		return ErrAddressNotFound
+	}
+	if err != nil || !wasDefault {
+		return err
+	}
+	_, err = txn.ExecContext(ctx,
+		"UPDATE addresses SET is_default = true WHERE id = (SELECT id FROM addresses WHERE account_id=$1 ORDER BY created_at DESC LIMIT 1)",
+		accountID,
+	)
+	return err
+}
+
+func (r *postgresRepository) SetDefaultAddress(ctx context.Context, accountID, addressID string) (_ *Address, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "SetDefaultAddress")
+	defer func() { done(err) }()
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	if _, err = txn.ExecContext(ctx, "UPDATE addresses SET is_default = false, updated_at = now() WHERE account_id=$1 AND is_default AND id <> $2", accountID, addressID); err != nil {
+		return nil, err
+	}
+	row := txn.QueryRowContext(ctx, "UPDATE addresses SET is_default = true, updated_at = now() WHERE id=$1 AND account_id=$2 RETURNING "+addressColumns, addressID, accountID)
+	a, err := scanAddress(row)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,63 @@
+package account
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+// Deletion states GetAccounts can filter on. The zero value only returns
+// accounts that are not deleted.
+const (
+	DeletionActive  = ""
+	DeletionDeleted = "deleted"
+	DeletionAny     = "any"
+)
+
+var ErrInvalidCursor = errs.InvalidArgument("after", "invalid cursor")
+
+// AccountFilter narrows GetAccounts. Zero values don't filter.
+type AccountFilter struct {
+	// Query matches part of the name, email or phone
+	Query         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	EmailVerified *bool
+	PhoneVerified *bool
+	Deletion      string
+}
+
+// AccountCursor is the (created_at, id) position of the last account on a
+// page. Accounts are listed newest first, so the next page holds the
+// accounts that sort strictly after it.
+type AccountCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (c AccountCursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeAccountCursor(s string) (*AccountCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &AccountCursor{CreatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
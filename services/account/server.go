@@ -6,7 +6,15 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/account/pb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -17,16 +25,58 @@ type grpcServer struct {
 	service Service
 }
 
-func ListenGrpcServer(s Service, port int) error {
+// roleRules lists the RPCs that need an access token with one of the roles.
+// RPCs with no roles take any signed-in caller, and their handlers check the
+// caller owns the account with auth.RequireSubject.
+var roleRules = map[string][]string{
+	pb.AccountService_GetAccounts_FullMethodName:     {auth.RoleAdmin, auth.RoleSupport},
+	pb.AccountService_SetAccountRoles_FullMethodName: {auth.RoleAdmin},
+	// Money only moves when checkout, or another service, says so
+	pb.AccountService_TopUpWallet_FullMethodName: {auth.RoleService},
+	pb.AccountService_DebitWallet_FullMethodName: {auth.RoleService},
+
+	pb.AccountService_Logout_FullMethodName:                 {},
+	pb.AccountService_LogoutAll_FullMethodName:              {},
+	pb.AccountService_ListSessions_FullMethodName:           {},
+	pb.AccountService_GetWalletBalance_FullMethodName:       {},
+	pb.AccountService_ListWalletTransactions_FullMethodName: {},
+	pb.AccountService_CreateAddress_FullMethodName:          {},
+	pb.AccountService_ListAddresses_FullMethodName:          {},
+	pb.AccountService_UpdateAddress_FullMethodName:          {},
+	pb.AccountService_DeleteAddress_FullMethodName:          {},
+	pb.AccountService_SetDefaultAddress_FullMethodName:      {},
+	pb.AccountService_GetAccount_FullMethodName:             {},
+	pb.AccountService_SendVerification_FullMethodName:       {},
+	pb.AccountService_VerifyPhone_FullMethodName:            {},
+	pb.AccountService_UpdateAccount_FullMethodName:          {},
+	pb.AccountService_ChangePassword_FullMethodName:         {},
+	pb.AccountService_DeleteAccount_FullMethodName:          {},
+}
+
+// ListenGrpcServer serves the account service until ctx is done, checking
+// protected RPCs with verify, or serviceKey for other services, and
+// reporting health from checks. On shutdown it keeps serving for drainDelay
+// while reporting NOT_SERVING, then gives in-flight RPCs up to
+// shutdownTimeout to finish.
+func ListenGrpcServer(ctx context.Context, s Service, verify auth.Verifier, serviceKey string, checks health.Checks, port int, drainDelay, shutdownTimeout time.Duration) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			metrics.UnaryServerInterceptor(),
+			logging.UnaryServerInterceptor(),
+			errs.UnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(verify, serviceKey, roleRules),
+		),
+	)
 	pb.RegisterAccountServiceServer(server, &grpcServer{UnimplementedAccountServiceServer: pb.UnimplementedAccountServiceServer{}, service: s})
+	hs := health.Register(ctx, server, pb.AccountService_ServiceDesc.ServiceName, checks)
 	reflection.Register(server)
-	return server.Serve(lis)
+	return lifecycle.ServeGRPC(ctx, server, hs, lis, drainDelay, shutdownTimeout)
 }
 
 func (server *grpcServer) PostAccount(ctx context.Context, r *pb.PostAccountRequest) (*pb.PostAccountResponse, error) {
@@ -57,21 +107,382 @@ func (server *grpcServer) LoginAccount(ctx context.Context, r *pb.LoginRequest)
 	}, nil
 }
 
+func (server *grpcServer) RequestOTP(ctx context.Context, r *pb.RequestOTPRequest) (*pb.RequestOTPResponse, error) {
+	if err := server.service.RequestOTP(ctx, r.Identifier); err != nil {
+		return nil, err
+	}
+	return &pb.RequestOTPResponse{}, nil
+}
+
+func (server *grpcServer) VerifyOTP(ctx context.Context, r *pb.VerifyOTPRequest) (*pb.LoginResponse, error) {
+	account, accessToken, refreshToken, err := server.service.VerifyOTP(ctx, r.Identifier, r.Code, r.Ip, r.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LoginResponse{
+		Id:           account.ID,
+		Name:         account.Name,
+		Email:        account.Email,
+		Phone:        account.Phone,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (server *grpcServer) RefreshToken(ctx context.Context, r *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	accessToken, refreshToken, err := server.service.RefreshToken(ctx, r.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (server *grpcServer) Logout(ctx context.Context, r *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	if err := auth.RequireSubject(ctx, r.UserId, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := server.service.LogoutBySession(ctx, r.UserId, r.SessionId); err != nil {
+		return nil, err
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+func (server *grpcServer) LogoutAll(ctx context.Context, r *pb.LogoutAllRequest) (*pb.LogoutAllResponse, error) {
+	if err := auth.RequireSubject(ctx, r.UserId, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := server.service.LogoutAllSessions(ctx, r.UserId); err != nil {
+		return nil, err
+	}
+	return &pb.LogoutAllResponse{}, nil
+}
+
+func (server *grpcServer) ListSessions(ctx context.Context, r *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	if err := auth.RequireSubject(ctx, r.UserId, auth.RoleAdmin, auth.RoleSupport); err != nil {
+		return nil, err
+	}
+	res, err := server.service.ListActiveSessions(ctx, r.UserId)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*pb.Session{}
+	for _, session := range res {
+		sessions = append(sessions, &pb.Session{
+			Id:        session.ID,
+			Ip:        session.IP,
+			UserAgent: session.UserAgent,
+			CreatedAt: session.CreatedAt,
+		})
+	}
+	return &pb.ListSessionsResponse{
+		Sessions: sessions,
+	}, nil
+}
+
+func (server *grpcServer) TopUpWallet(ctx context.Context, r *pb.WalletPostingRequest) (*pb.WalletPostingResponse, error) {
+	t, err := server.service.TopUpWallet(ctx, r.AccountId, r.Amount, r.IdempotencyKey, r.Reference)
+	if err != nil {
+		return nil, err
+	}
+	return server.walletPostingResponse(ctx, r.AccountId, t)
+}
+
+func (server *grpcServer) DebitWallet(ctx context.Context, r *pb.WalletPostingRequest) (*pb.WalletPostingResponse, error) {
+	t, err := server.service.DebitWallet(ctx, r.AccountId, r.Amount, r.IdempotencyKey, r.Reference)
+	if err != nil {
+		return nil, err
+	}
+	return server.walletPostingResponse(ctx, r.AccountId, t)
+}
+
+func (server *grpcServer) walletPostingResponse(ctx context.Context, accountID string, t *WalletTransaction) (*pb.WalletPostingResponse, error) {
+	wallet, err := server.service.GetWalletBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.WalletPostingResponse{
+		Transaction: walletTransactionToProto(t),
+		Balance:     wallet.Balance,
+	}, nil
+}
+
+func (server *grpcServer) GetWalletBalance(ctx context.Context, r *pb.GetWalletBalanceRequest) (*pb.GetWalletBalanceResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin, auth.RoleSupport); err != nil {
+		return nil, err
+	}
+	wallet, err := server.service.GetWalletBalance(ctx, r.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetWalletBalanceResponse{
+		WalletId: wallet.ID,
+		Currency: wallet.Currency,
+		Balance:  wallet.Balance,
+	}, nil
+}
+
+func (server *grpcServer) ListWalletTransactions(ctx context.Context, r *pb.ListWalletTransactionsRequest) (*pb.ListWalletTransactionsResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin, auth.RoleSupport); err != nil {
+		return nil, err
+	}
+	res, err := server.service.ListWalletTransactions(ctx, r.AccountId, r.Skip, r.Take)
+	if err != nil {
+		return nil, err
+	}
+	transactions := []*pb.WalletTransaction{}
+	for _, t := range res {
+		transactions = append(transactions, walletTransactionToProto(&t))
+	}
+	return &pb.ListWalletTransactionsResponse{
+		Transactions: transactions,
+	}, nil
+}
+
+func walletTransactionToProto(t *WalletTransaction) *pb.WalletTransaction {
+	return &pb.WalletTransaction{
+		Id:        t.ID,
+		WalletId:  t.WalletID,
+		Kind:      t.Kind,
+		Amount:    t.Amount,
+		Reference: t.Reference,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (server *grpcServer) CreateAddress(ctx context.Context, r *pb.AddressRequest) (*pb.AddressResponse, error) {
+	address := addressFromProto(r.Address)
+	if err := auth.RequireSubject(ctx, address.AccountID, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	a, err := server.service.CreateAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddressResponse{Address: addressToProto(a)}, nil
+}
+
+func (server *grpcServer) ListAddresses(ctx context.Context, r *pb.ListAddressesRequest) (*pb.ListAddressesResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin, auth.RoleSupport); err != nil {
+		return nil, err
+	}
+	res, err := server.service.ListAddresses(ctx, r.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	addresses := []*pb.Address{}
+	for _, a := range res {
+		addresses = append(addresses, addressToProto(&a))
+	}
+	return &pb.ListAddressesResponse{Addresses: addresses}, nil
+}
+
+func (server *grpcServer) UpdateAddress(ctx context.Context, r *pb.AddressRequest) (*pb.AddressResponse, error) {
+	address := addressFromProto(r.Address)
+	if err := auth.RequireSubject(ctx, address.AccountID, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	a, err := server.service.UpdateAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddressResponse{Address: addressToProto(a)}, nil
+}
+
+func (server *grpcServer) DeleteAddress(ctx context.Context, r *pb.AddressIdRequest) (*pb.DeleteAddressResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := server.service.DeleteAddress(ctx, r.AccountId, r.AddressId); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteAddressResponse{}, nil
+}
+
+func (server *grpcServer) SetDefaultAddress(ctx context.Context, r *pb.AddressIdRequest) (*pb.AddressResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	a, err := server.service.SetDefaultAddress(ctx, r.AccountId, r.AddressId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddressResponse{Address: addressToProto(a)}, nil
+}
+
+func addressToProto(a *Address) *pb.Address {
+	return &pb.Address{
+		Id:        a.ID,
+		AccountId: a.AccountID,
+		Label:     a.Label,
+		Line1:     a.Line1,
+		Line2:     a.Line2,
+		Landmark:  a.Landmark,
+		City:      a.City,
+		State:     a.State,
+		Pincode:   a.Pincode,
+		Latitude:  a.Latitude,
+		Longitude: a.Longitude,
+		IsDefault: a.IsDefault,
+	}
+}
+
+func addressFromProto(a *pb.Address) Address {
+	if a == nil {
+		return Address{}
+	}
+	return Address{
+		ID:        a.Id,
+		AccountID: a.AccountId,
+		Label:     a.Label,
+		Line1:     a.Line1,
+		Line2:     a.Line2,
+		Landmark:  a.Landmark,
+		City:      a.City,
+		State:     a.State,
+		Pincode:   a.Pincode,
+		Latitude:  a.Latitude,
+		Longitude: a.Longitude,
+		IsDefault: a.IsDefault,
+	}
+}
+
+func (server *grpcServer) GetAccount(ctx context.Context, r *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	if err := auth.RequireSubject(ctx, r.Id, auth.RoleAdmin, auth.RoleSupport, auth.RoleService); err != nil {
+		return nil, err
+	}
+	account, err := server.service.GetAccountById(ctx, r.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetAccountResponse{
+		Id:            account.ID,
+		Name:          account.Name,
+		Email:         account.Email,
+		Phone:         account.Phone,
+		EmailVerified: account.EmailVerified,
+		PhoneVerified: account.PhoneVerified,
+		Roles:         account.Roles,
+		CreatedAt:     account.CreatedAt.Unix(),
+	}, nil
+}
+
+func (server *grpcServer) SetAccountRoles(ctx context.Context, r *pb.SetAccountRolesRequest) (*pb.SetAccountRolesResponse, error) {
+	if err := server.service.SetAccountRoles(ctx, r.Id, r.Roles); err != nil {
+		return nil, err
+	}
+	return &pb.SetAccountRolesResponse{}, nil
+}
+
+func (server *grpcServer) SendVerification(ctx context.Context, r *pb.SendVerificationRequest) (*pb.VerificationResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId); err != nil {
+		return nil, err
+	}
+	if err := server.service.SendVerification(ctx, r.AccountId, r.Channel); err != nil {
+		return nil, err
+	}
+	return &pb.VerificationResponse{}, nil
+}
+
+func (server *grpcServer) VerifyEmail(ctx context.Context, r *pb.VerifyEmailRequest) (*pb.VerificationResponse, error) {
+	if err := server.service.VerifyEmail(ctx, r.Token); err != nil {
+		return nil, err
+	}
+	return &pb.VerificationResponse{}, nil
+}
+
+func (server *grpcServer) VerifyPhone(ctx context.Context, r *pb.VerifyPhoneRequest) (*pb.VerificationResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId); err != nil {
+		return nil, err
+	}
+	if err := server.service.VerifyPhone(ctx, r.AccountId, r.Code); err != nil {
+		return nil, err
+	}
+	return &pb.VerificationResponse{}, nil
+}
+
+func (server *grpcServer) UpdateAccount(ctx context.Context, r *pb.UpdateAccountRequest) (*pb.UpdateAccountResponse, error) {
+	if err := auth.RequireSubject(ctx, r.Id, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	account, err := server.service.UpdateAccount(ctx, r.Id, r.Name, r.Email, r.Phone)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpdateAccountResponse{
+		Account: &pb.Account{
+			Id:            account.ID,
+			Name:          account.Name,
+			Email:         account.Email,
+			Phone:         account.Phone,
+			EmailVerified: account.EmailVerified,
+			PhoneVerified: account.PhoneVerified,
+			Roles:         account.Roles,
+		},
+	}, nil
+}
+
+func (server *grpcServer) ChangePassword(ctx context.Context, r *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	if err := auth.RequireSubject(ctx, r.Id); err != nil {
+		return nil, err
+	}
+	if err := server.service.ChangePassword(ctx, r.Id, r.OldPassword, r.NewPassword); err != nil {
+		return nil, err
+	}
+	return &pb.ChangePasswordResponse{}, nil
+}
+
+func (server *grpcServer) DeleteAccount(ctx context.Context, r *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	if err := auth.RequireSubject(ctx, r.Id, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := server.service.DeleteAccount(ctx, r.Id); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteAccountResponse{}, nil
+}
+
 func (server *grpcServer) GetAccounts(ctx context.Context, r *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
-	res, err := server.service.GetAccounts(ctx, r.Skip, r.Take)
+	filter := AccountFilter{
+		Query:         r.Query,
+		EmailVerified: r.EmailVerified,
+		PhoneVerified: r.PhoneVerified,
+	}
+	if r.CreatedAfter != 0 {
+		filter.CreatedAfter = time.Unix(r.CreatedAfter, 0)
+	}
+	if r.CreatedBefore != 0 {
+		filter.CreatedBefore = time.Unix(r.CreatedBefore, 0)
+	}
+	switch r.Deletion {
+	case pb.DeletionFilter_DELETION_DELETED:
+		filter.Deletion = DeletionDeleted
+	case pb.DeletionFilter_DELETION_ANY:
+		filter.Deletion = DeletionAny
+	}
+
+	res, next, err := server.service.GetAccounts(ctx, filter, r.After, r.Skip, r.Take)
 	if err != nil {
 		return nil, err
 	}
 	accounts := []*pb.Account{}
 	for _, account := range res {
 		accounts = append(accounts, &pb.Account{
-			Id:    account.ID,
-			Name:  account.Name,
-			Email: account.Email,
-			Phone: account.Phone,
+			Id:            account.ID,
+			Name:          account.Name,
+			Email:         account.Email,
+			Phone:         account.Phone,
+			EmailVerified: account.EmailVerified,
+			PhoneVerified: account.PhoneVerified,
+			Roles:         account.Roles,
+			CreatedAt:     account.CreatedAt.Unix(),
+			Deleted:       account.Deleted,
 		})
 	}
 	return &pb.GetAccountsResponse{
-		Accounts: accounts,
+		Accounts:   accounts,
+		NextCursor: next,
 	}, nil
 }
@@ -3,17 +3,27 @@ package account
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+var (
+	ErrInvalidCredentials = errs.Unauthenticated("invalid Credentials")
+	ErrEmailTaken         = errs.AlreadyExists("email", "email is already registered")
+	ErrPhoneTaken         = errs.AlreadyExists("phone", "phone is already registered")
+	ErrAccountNotFound    = errs.NotFound("account not found")
+	ErrSessionNotFound    = errs.NotFound("session not found")
 )
 
 type SessionData struct {
+	ID          string `json:"-"`
 	UserID      string `json:"userId"`
 	IP          string `json:"ip"`
 	UserAgent   string `json:"userAgent"`
@@ -21,29 +31,87 @@ type SessionData struct {
 	CreatedAt   int64  `json:"createdAt"`
 }
 
+// SessionVerifier wraps verify to also reject access tokens whose session
+// was logged out, deleted or expired before the token itself.
+func SessionVerifier(verify auth.Verifier, redisClient *redis.Client) auth.Verifier {
+	return func(ctx context.Context, token string) (*auth.Claims, error) {
+		claims, err := verify(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		val, err := redisClient.Get(ctx, "session:"+claims.SessionID).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up session: %w", err)
+		}
+		var session SessionData
+		if err := json.Unmarshal([]byte(val), &session); err != nil || session.UserID != claims.UserID {
+			return nil, ErrSessionNotFound
+		}
+		return claims, nil
+	}
+}
+
 type Service interface {
 	PostAccount(ctx context.Context, name, email, phone, password string) (*Account, error)
 	LoginAccount(ctx context.Context, emailorphone, password, ip, userAgent string) (*Account, string, string, error)
+	RefreshToken(ctx context.Context, refreshToken string) (string, string, error)
+	RequestOTP(ctx context.Context, identifier string) error
+	VerifyOTP(ctx context.Context, identifier, code, ip, userAgent string) (*Account, string, string, error)
 	GetAccountById(ctx context.Context, id string) (*Account, error)
-	GetAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error)
+	GetAccounts(ctx context.Context, filter AccountFilter, after string, skip uint64, take uint64) ([]Account, string, error)
+	SetAccountRoles(ctx context.Context, id string, roles []string) error
+	UpdateAccount(ctx context.Context, id, name, email, phone string) (*Account, error)
+	ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error
+	DeleteAccount(ctx context.Context, id string) error
+	AnonymizeDeletedAccounts(ctx context.Context) (int64, error)
+	SendVerification(ctx context.Context, accountID, channel string) error
+	VerifyEmail(ctx context.Context, token string) error
+	VerifyPhone(ctx context.Context, accountID, code string) error
 	LogoutBySession(ctx context.Context, userID, sessionID string) error
 	LogoutAllSessions(ctx context.Context, userID string) error
 	ListActiveSessions(ctx context.Context, userID string) ([]SessionData, error)
+	TopUpWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, error)
+	DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, error)
+	GetWalletBalance(ctx context.Context, accountID string) (*Wallet, error)
+	ListWalletTransactions(ctx context.Context, accountID string, skip, take uint64) ([]WalletTransaction, error)
+	CreateAddress(ctx context.Context, address Address) (*Address, error)
+	ListAddresses(ctx context.Context, accountID string) ([]Address, error)
+	UpdateAddress(ctx context.Context, address Address) (*Address, error)
+	DeleteAddress(ctx context.Context, accountID, addressID string) error
+	SetDefaultAddress(ctx context.Context, accountID, addressID string) (*Address, error)
 }
 
 type accountService struct {
 	repository  Repository
 	redisClient *redis.Client
+	otpSender   OTPSender
+	tokens      *TokenIssuer
+	passwords   *PasswordPolicy
+	contacts    *ContactNormalizer
+	config      Config
 }
 
-func NewService(r Repository, redisClient *redis.Client) Service {
-	return &accountService{r, redisClient}
+func NewService(cfg Config, r Repository, redisClient *redis.Client, otpSender OTPSender, tokens *TokenIssuer, passwords *PasswordPolicy, contacts *ContactNormalizer) Service {
+	return &accountService{r, redisClient, otpSender, tokens, passwords, contacts, cfg}
 }
 
+// PostAccount creates the account, returning ErrEmailTaken or ErrPhoneTaken
+// when another account already uses the email or phone.
 func (as *accountService) PostAccount(ctx context.Context, name, email, phone, password string) (*Account, error) {
+	email, err := as.contacts.Email(email)
+	if err != nil {
+		return nil, err
+	}
+	phone, err = as.contacts.Phone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.passwords.Check("password", password); err != nil {
+		return nil, err
+	}
 	password_hash, err := HashPassword(password)
 	if err != nil {
-		log.Println("Error hashing password:", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	account := &Account{
@@ -57,29 +125,81 @@ func (as *accountService) PostAccount(ctx context.Context, name, email, phone, p
 	if err := as.repository.PutAccount(ctx, *account); err != nil {
 		return nil, err
 	}
+	as.sendSignupVerifications(ctx, account.ID)
 	return account, nil
 }
 
 func (as *accountService) LoginAccount(ctx context.Context, emailOrPhone, password, ip, userAgent string) (*Account, string, string, error) {
-	queryKey, err := checkPhoneorEmail(emailOrPhone)
+	queryKey, emailOrPhone, err := as.contacts.Identifier(emailOrPhone)
 	if err != nil {
-		log.Println(err)
+		return nil, "", "", err
+	}
+
+	if err := as.checkLoginAllowed(ctx, emailOrPhone, ip); err != nil {
+		return nil, "", "", err
 	}
 
 	account, err := as.repository.GetAccount(ctx, queryKey, emailOrPhone)
+	if err == nil && !CompareHashPassword(account.Password, password) {
+		err = ErrInvalidCredentials
+	}
 	if err != nil {
+		if isLoginFailure(err) {
+			as.recordLoginFailure(ctx, emailOrPhone, ip)
+			return nil, "", "", ErrInvalidCredentials
+		}
 		return nil, "", "", err
 	}
-	if !CompareHashPassword(account.Password, password) {
-		return nil, "", "", errors.New("invalid Credentials")
-	}
+	as.resetLoginFailures(ctx, emailOrPhone)
 
-	accessToken, refreshToken, err := GenerateJWT(account.ID)
+	accessToken, refreshToken, err := as.createSession(ctx, account, ip, userAgent)
 	if err != nil {
 		return nil, "", "", err
 	}
+
+	return account, accessToken, refreshToken, nil
+}
+
+func (as *accountService) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
+	claims, err := as.tokens.VerifyRefreshJWT(refreshToken)
+	if err != nil {
+		slog.InfoContext(ctx, "Rejected refresh token", "err", err)
+		return "", "", errs.Unauthenticated("invalid refresh token")
+	}
+
+	// Spend the token: only the first caller gets to delete the key
+	deleted, err := as.redisClient.Del(ctx, "refresh-token:"+claims.ID).Result()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to spend refresh token: %w", err)
+	}
+	if deleted == 0 {
+		// A validly signed token that was already spent means it leaked,
+		// so revoke every session of the user
+		if err := as.LogoutAllSessions(ctx, claims.UserID); err != nil {
+			slog.ErrorContext(ctx, "Error revoking sessions after refresh token reuse", "account_id", claims.UserID, "err", err)
+		}
+		return "", "", errs.Unauthenticated("refresh token reuse detected")
+	}
+
+	exists, err := as.redisClient.Exists(ctx, "session:"+claims.SessionID).Result()
+	if err != nil || exists == 0 {
+		return "", "", errs.Unauthenticated("session not found")
+	}
+
+	// Pick up role changes made since the last token was issued
+	account, err := as.repository.GetAccount(ctx, "id", claims.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return as.issueTokens(ctx, account, claims.SessionID)
+}
+
+// createSession stores a new Redis session for the user and issues the
+// token pair bound to it.
+func (as *accountService) createSession(ctx context.Context, account *Account, ip, userAgent string) (string, string, error) {
 	// Generate fingerprint
-	fingerprint := generateFingerprint(ip, userAgent)
+	fingerprint := GenerateFingerprint(ip, userAgent)
 
 	// Create session ID (random UUID recommended)
 	sessionID := uuid.New().String()
@@ -93,23 +213,51 @@ func (as *accountService) LoginAccount(ctx context.Context, emailOrPhone, passwo
 		CreatedAt:   time.Now().Unix(),
 	}
 
-	// Store session in Redis for 7 days
+	// Store session in Redis until SESSION_TTL passes
 	sessionJSON, _ := json.Marshal(session)
-	as.redisClient.Set(ctx, "session:"+sessionID, sessionJSON, 7*24*time.Hour)
+	as.redisClient.Set(ctx, "session:"+sessionID, sessionJSON, as.config.SessionTTL)
 	as.redisClient.SAdd(ctx, "user-sessions:"+account.ID, sessionID)
 
-	return account, accessToken, refreshToken, nil
+	return as.issueTokens(ctx, account, sessionID)
 }
 
-func (as *accountService) GetAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error) {
-	if take > 100 || (skip == 0 && take == 0) {
+// issueTokens creates a new access/refresh pair for the session and records
+// the refresh token id in Redis so it can be spent once by RefreshToken.
+func (as *accountService) issueTokens(ctx context.Context, account *Account, sessionID string) (string, string, error) {
+	refreshID := uuid.New().String()
+	accessToken, refreshToken, err := as.tokens.GenerateJWT(account.ID, sessionID, refreshID, account.Roles)
+	if err != nil {
+		return "", "", err
+	}
+	err = as.redisClient.Set(ctx, "refresh-token:"+refreshID, account.ID, as.config.RefreshTokenTTL).Err()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return accessToken, refreshToken, nil
+}
+
+// GetAccounts returns a page of accounts matching filter, starting after the
+// cursor, along with the cursor for the next page ("" on the last page).
+func (as *accountService) GetAccounts(ctx context.Context, filter AccountFilter, after string, skip uint64, take uint64) ([]Account, string, error) {
+	if take == 0 || take > 100 {
 		take = 100
 	}
-	accounts, err := as.repository.ListAccounts(ctx, skip, take)
+	cursor, err := DecodeAccountCursor(after)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	// Fetch one extra row to learn whether another page follows
+	accounts, err := as.repository.ListAccounts(ctx, filter, cursor, skip, take+1)
+	if err != nil {
+		return nil, "", err
 	}
-	return accounts, nil
+	next := ""
+	if uint64(len(accounts)) > take && len(accounts) > 0 {
+		accounts = accounts[:take]
+		last := accounts[len(accounts)-1]
+		next = AccountCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return accounts, next, nil
 }
 
 func (as *accountService) GetAccountById(ctx context.Context, id string) (*Account, error) {
@@ -121,12 +269,98 @@ func (as *accountService) GetAccountById(ctx context.Context, id string) (*Accou
 	return account, nil
 }
 
+// SetAccountRoles replaces the account's roles. They take effect in tokens
+// issued from the next login or refresh.
+func (as *accountService) SetAccountRoles(ctx context.Context, id string, roles []string) error {
+	if len(roles) == 0 {
+		return errs.InvalidArgument("roles", "an account needs at least one role")
+	}
+	for _, role := range roles {
+		switch role {
+		case auth.RoleCustomer, auth.RoleAdmin, auth.RoleCatalogManager, auth.RoleSupport:
+		default:
+			return errs.InvalidArgument("roles", "unknown role: "+role)
+		}
+	}
+	return as.repository.SetAccountRoles(ctx, id, roles)
+}
+
+// UpdateAccount changes the profile fields that are not empty.
+func (as *accountService) UpdateAccount(ctx context.Context, id, name, email, phone string) (*Account, error) {
+	account, err := as.repository.GetAccount(ctx, "id", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		account.Name = name
+	}
+	if email != "" {
+		if account.Email, err = as.contacts.Email(email); err != nil {
+			return nil, err
+		}
+	}
+	if phone != "" {
+		if account.Phone, err = as.contacts.Phone(phone); err != nil {
+			return nil, err
+		}
+	}
+
+	return as.repository.UpdateAccount(ctx, *account)
+}
+
+// ChangePassword replaces the password after checking the old one and signs
+// the user out everywhere.
+func (as *accountService) ChangePassword(ctx context.Context, id, oldPassword, newPassword string) error {
+	account, err := as.repository.GetAccount(ctx, "id", id)
+	if err != nil {
+		return err
+	}
+	if !CompareHashPassword(account.Password, oldPassword) {
+		return ErrInvalidCredentials
+	}
+	if err := as.passwords.Check("new_password", newPassword); err != nil {
+		return err
+	}
+
+	passwordHash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := as.repository.UpdatePassword(ctx, id, passwordHash); err != nil {
+		return err
+	}
+	return as.LogoutAllSessions(ctx, id)
+}
+
+// DeleteAccount soft-deletes the account and ends its sessions. PII is
+// scrubbed by AnonymizeDeletedAccounts once the grace period has passed.
+func (as *accountService) DeleteAccount(ctx context.Context, id string) error {
+	if err := as.repository.SoftDeleteAccount(ctx, id); err != nil {
+		return err
+	}
+	return as.LogoutAllSessions(ctx, id)
+}
+
+func (as *accountService) AnonymizeDeletedAccounts(ctx context.Context) (int64, error) {
+	return as.repository.AnonymizeDeletedAccounts(ctx, time.Now().Add(-as.config.AccountDeletionGrace))
+}
+
 func (as *accountService) LogoutBySession(ctx context.Context, userID, sessionID string) error {
 	sessionKey := fmt.Sprintf("session:%s", sessionID)
 	userSessionsKey := fmt.Sprintf("user-sessions:%s", userID)
 
+	// Only the owner may revoke a session
+	owned, err := as.redisClient.SIsMember(ctx, userSessionsKey, sessionID).Result()
+	if err != nil {
+		return fmt.Errorf("failed to look up session: %w", err)
+	}
+	if !owned {
+		return ErrSessionNotFound
+	}
+
 	// Delete the session key
-	err := as.redisClient.Del(ctx, sessionKey).Err()
+	err = as.redisClient.Del(ctx, sessionKey).Err()
 	if err != nil {
 		return fmt.Errorf("failed to delete session key: %w", err)
 	}
@@ -150,7 +384,7 @@ func (as *accountService) LogoutAllSessions(ctx context.Context, userID string)
 	for _, sid := range sessionIDs {
 		sessionKey := fmt.Sprintf("session:%s", sid)
 		if err := as.redisClient.Del(ctx, sessionKey).Err(); err != nil {
-			fmt.Printf("Warning: failed to delete session %s: %v\n", sid, err)
+			slog.WarnContext(ctx, "Failed to delete session", "session_id", sid, "err", err)
 		}
 	}
 
@@ -184,6 +418,7 @@ func (as *accountService) ListActiveSessions(ctx context.Context, userID string)
 		if err := json.Unmarshal([]byte(val), &s); err != nil {
 			continue
 		}
+		s.ID = sid
 
 		sessions = append(sessions, s)
 	}
@@ -0,0 +1,136 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeRepository implements the parts of Repository the tests below reach.
+// Anything else panics on the nil embedded interface.
+type fakeRepository struct {
+	Repository
+	accounts []Account
+	// take as ListAccounts was last asked for
+	take uint64
+	// posted wallet transactions
+	posted []WalletTransaction
+}
+
+func (r *fakeRepository) ListAccounts(ctx context.Context, filter AccountFilter, after *AccountCursor, skip uint64, take uint64) ([]Account, error) {
+	r.take = take
+	if take > uint64(len(r.accounts)) {
+		take = uint64(len(r.accounts))
+	}
+	return r.accounts[:take], nil
+}
+
+func (r *fakeRepository) GetOrCreateWallet(ctx context.Context, accountID, newWalletID string) (*Wallet, error) {
+	return &Wallet{ID: "wallet-" + accountID, AccountID: accountID, Currency: "INR"}, nil
+}
+
+func (r *fakeRepository) PostWalletTransaction(ctx context.Context, t WalletTransaction) (*WalletTransaction, error) {
+	r.posted = append(r.posted, t)
+	return &t, nil
+}
+
+func testAccounts(n int) []Account {
+	accounts := []Account{}
+	for i := range n {
+		accounts = append(accounts, Account{ID: fmt.Sprintf("a%d", i), CreatedAt: time.Unix(int64(i), 0)})
+	}
+	return accounts
+}
+
+func TestGetAccounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    int
+		take      uint64
+		wantTake  uint64
+		wantCount int
+		wantNext  bool
+	}{
+		{name: "zero take defaults to 100", stored: 3, take: 0, wantTake: 101, wantCount: 3},
+		{name: "take capped at 100", stored: 150, take: 500, wantTake: 101, wantCount: 100, wantNext: true},
+		{name: "more pages follow", stored: 3, take: 2, wantTake: 3, wantCount: 2, wantNext: true},
+		{name: "exactly one page", stored: 2, take: 2, wantTake: 3, wantCount: 2},
+		{name: "no accounts", stored: 0, take: 10, wantTake: 11, wantCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRepository{accounts: testAccounts(tt.stored)}
+			as := &accountService{repository: r}
+
+			accounts, next, err := as.GetAccounts(context.Background(), AccountFilter{}, "", 0, tt.take)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.take != tt.wantTake {
+				t.Errorf("repository asked for %d accounts, want %d", r.take, tt.wantTake)
+			}
+			if len(accounts) != tt.wantCount {
+				t.Errorf("got %d accounts, want %d", len(accounts), tt.wantCount)
+			}
+			if (next != "") != tt.wantNext {
+				t.Errorf("next cursor = %q, want one: %v", next, tt.wantNext)
+			}
+			if next != "" {
+				cursor, err := DecodeAccountCursor(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if last := accounts[len(accounts)-1]; cursor.ID != last.ID || !cursor.CreatedAt.Equal(last.CreatedAt) {
+					t.Errorf("cursor = %+v, want the last account %s", cursor, last.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestWalletTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		amount  int64
+		key     string
+		wantErr error
+	}{
+		{name: "top-up", kind: WalletTopUp, amount: 500, key: "topup-1"},
+		{name: "top-up without key", kind: WalletTopUp, amount: 500},
+		{name: "debit", kind: WalletDebit, amount: 250, key: "order:1"},
+		{name: "debit without key", kind: WalletDebit, amount: 250, wantErr: ErrIdempotencyKeyRequired},
+		{name: "zero amount", kind: WalletTopUp, amount: 0, key: "k", wantErr: ErrInvalidAmount},
+		{name: "negative debit", kind: WalletDebit, amount: -100, key: "k", wantErr: ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRepository{}
+			as := &accountService{repository: r}
+
+			post := as.TopUpWallet
+			if tt.kind == WalletDebit {
+				post = as.DebitWallet
+			}
+			tx, err := post(context.Background(), "acc", tt.amount, tt.key, "ref")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(r.posted) != 0 {
+					t.Errorf("posted %d transactions for a rejected request", len(r.posted))
+				}
+				return
+			}
+			if len(r.posted) != 1 {
+				t.Fatalf("posted %d transactions, want 1", len(r.posted))
+			}
+			if tx.WalletID != "wallet-acc" || tx.Kind != tt.kind || tx.Amount != tt.amount ||
+				tx.IdempotencyKey != tt.key || tx.Reference != "ref" {
+				t.Errorf("posted %+v", tx)
+			}
+		})
+	}
+}
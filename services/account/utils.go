@@ -3,8 +3,6 @@ package account
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"errors"
-	"regexp"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -29,21 +27,8 @@ func isAllowedKey(key string) bool {
 	return isExists
 }
 
-func checkPhoneorEmail(emailOrPhone string) (string, error) {
-	emailRegex := regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
-	phoneRegex := regexp.MustCompile(`^\+?\d{10,15}$`)
-
-	switch {
-	case emailRegex.MatchString(emailOrPhone):
-		return "email", nil
-	case phoneRegex.MatchString(emailOrPhone):
-		return "phone", nil
-	default:
-		return "", errors.New("input must be a valid email or phone number")
-	}
-}
-
-func generateFingerprint(ip, userAgent string) string {
+// GenerateFingerprint identifies the client a session was created from.
+func GenerateFingerprint(ip, userAgent string) string {
 	data := ip + userAgent
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
@@ -0,0 +1,92 @@
+package account
+
+import (
+	"context"
+
+	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+const (
+	WalletTopUp = "topup"
+	WalletDebit = "debit"
+)
+
+var (
+	ErrInvalidAmount          = errs.InvalidArgument("amount", "amount must be positive")
+	ErrInsufficientFunds      = errs.FailedPrecondition("insufficient wallet balance")
+	ErrIdempotencyKeyRequired = errs.InvalidArgument("idempotency_key", "idempotency key is required")
+	ErrIdempotencyConflict    = errs.Conflict("idempotency key was already used for a different transaction")
+)
+
+// Wallet balances and amounts are in minor currency units (paise).
+type Wallet struct {
+	ID        string `json:"id"`
+	AccountID string `json:"accountId"`
+	Currency  string `json:"currency"`
+	Balance   int64  `json:"balance"`
+}
+
+type WalletTransaction struct {
+	ID             string `json:"id"`
+	WalletID       string `json:"walletId"`
+	Kind           string `json:"kind"`
+	Amount         int64  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey"`
+	Reference      string `json:"reference"`
+	CreatedAt      int64  `json:"createdAt"`
+}
+
+func (as *accountService) TopUpWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, error) {
+	return as.postWalletTransaction(ctx, accountID, WalletTopUp, amount, idempotencyKey, reference)
+}
+
+// DebitWallet charges the wallet. Retrying with the same idempotency key
+// returns the original transaction instead of charging twice.
+func (as *accountService) DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*WalletTransaction, error) {
+	if idempotencyKey == "" {
+		return nil, ErrIdempotencyKeyRequired
+	}
+	return as.postWalletTransaction(ctx, accountID, WalletDebit, amount, idempotencyKey, reference)
+}
+
+func (as *accountService) GetWalletBalance(ctx context.Context, accountID string) (*Wallet, error) {
+	wallet, err := as.repository.GetOrCreateWallet(ctx, accountID, ksuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	wallet.Balance, err = as.repository.GetWalletBalance(ctx, wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (as *accountService) ListWalletTransactions(ctx context.Context, accountID string, skip, take uint64) ([]WalletTransaction, error) {
+	if take > 100 || (skip == 0 && take == 0) {
+		take = 100
+	}
+	wallet, err := as.repository.GetOrCreateWallet(ctx, accountID, ksuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	return as.repository.ListWalletTransactions(ctx, wallet.ID, skip, take)
+}
+
+func (as *accountService) postWalletTransaction(ctx context.Context, accountID, kind string, amount int64, idempotencyKey, reference string) (*WalletTransaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	wallet, err := as.repository.GetOrCreateWallet(ctx, accountID, ksuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	return as.repository.PostWalletTransaction(ctx, WalletTransaction{
+		ID:             ksuid.New().String(),
+		WalletID:       wallet.ID,
+		Kind:           kind,
+		Amount:         amount,
+		IdempotencyKey: idempotencyKey,
+		Reference:      reference,
+	})
+}
@@ -0,0 +1,330 @@
+package catalog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+var (
+	ErrCategoryNotFound = errs.NotFound("category not found")
+	ErrCategoryExists   = errs.AlreadyExists("name", "a category with this name already exists under the same parent")
+	ErrCategoryCycle    = errs.InvalidArgument("parentId", "a category can't be moved under itself or its subcategories")
+	ErrCategoryNotEmpty = errs.FailedPrecondition("category still has subcategories or products")
+	ErrCategoryConflict = errs.Conflict("category was changed by someone else, try again")
+)
+
+// categoryTreeTTL is how long reads reuse a loaded category tree. Changes
+// made through another instance show up within it.
+const categoryTreeTTL = 5 * time.Second
+
+// Category is a node of the category tree. Root categories have no ParentID.
+// Siblings are ordered by Position, then by name.
+type Category struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentID    string `json:"parentId"`
+	Position    int32  `json:"position"`
+	// The stored revision this copy was read at. PutCategory only
+	// overwrites that revision, and creates the category when both are 0.
+	seqNo, primaryTerm int64
+}
+
+// CategoryNode is a category with its subcategories in order.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode
+}
+
+// categoryTree indexes every category. The tree is loaded whole for each
+// change, and reads such as product listings share a recent copy: a grocery
+// catalog has hundreds of categories, not millions.
+type categoryTree map[string]*Category
+
+func (cs *catalogService) loadCategoryTree(ctx context.Context) (categoryTree, error) {
+	categories, err := cs.repository.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree := categoryTree{}
+	for i := range categories {
+		tree[categories[i].ID] = &categories[i]
+	}
+	return tree, nil
+}
+
+// cachedCategoryTree is loadCategoryTree for reads that can do with a tree
+// up to categoryTreeTTL old. Changes must start from a fresh one.
+func (cs *catalogService) cachedCategoryTree(ctx context.Context) (categoryTree, error) {
+	cs.treeMu.Lock()
+	defer cs.treeMu.Unlock()
+	if cs.tree != nil && time.Since(cs.treeLoaded) < categoryTreeTTL {
+		return cs.tree, nil
+	}
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cs.tree, cs.treeLoaded = tree, time.Now()
+	return tree, nil
+}
+
+// forgetCategoryTree drops the cached tree after a change.
+func (cs *catalogService) forgetCategoryTree() {
+	cs.treeMu.Lock()
+	defer cs.treeMu.Unlock()
+	cs.tree = nil
+}
+
+// descendants returns id and the IDs of all categories below it. Each is
+// visited once, so a cycle left by concurrent moves can't loop forever.
+func (t categoryTree) descendants(id string) []string {
+	children := map[string][]string{}
+	for _, c := range t {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+	ids := []string{}
+	visited := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		id, queue = queue[0], queue[1:]
+		ids = append(ids, id)
+		for _, child := range children[id] {
+			if !visited[child] {
+				visited[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return ids
+}
+
+// cyclic reports whether following parents up from id comes back to a
+// category already passed, rather than ending at a root.
+func (t categoryTree) cyclic(id string) bool {
+	visited := map[string]bool{}
+	for c, ok := t[id]; ok && c.ParentID != ""; c, ok = t[c.ParentID] {
+		if visited[c.ID] {
+			return true
+		}
+		visited[c.ID] = true
+	}
+	return false
+}
+
+// nameTaken reports whether another child of parentID is called name.
+func (t categoryTree) nameTaken(parentID, name, exceptID string) bool {
+	for _, c := range t {
+		if c.ParentID == parentID && c.ID != exceptID && strings.EqualFold(c.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodes builds the subtrees rooted at the children of parentID.
+func (t categoryTree) nodes(parentID string) []*CategoryNode {
+	return t.subtrees(parentID, map[string]bool{parentID: true})
+}
+
+// subtrees is nodes, leaving out categories already visited so that a cycle
+// ends the branch instead of recursing forever.
+func (t categoryTree) subtrees(parentID string, visited map[string]bool) []*CategoryNode {
+	nodes := []*CategoryNode{}
+	for _, c := range t {
+		if c.ParentID == parentID && !visited[c.ID] {
+			visited[c.ID] = true
+			nodes = append(nodes, &CategoryNode{Category: *c, Children: t.subtrees(c.ID, visited)})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Position != nodes[j].Position {
+			return nodes[i].Position < nodes[j].Position
+		}
+		return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+	})
+	return nodes
+}
+
+func (cs *catalogService) CreateCategory(ctx context.Context, c Category) (*Category, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return nil, errs.InvalidArgument("name", "name is required")
+	}
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tree[c.ParentID]; c.ParentID != "" && !ok {
+		return nil, ErrCategoryNotFound
+	}
+	if tree.nameTaken(c.ParentID, c.Name, "") {
+		return nil, ErrCategoryExists
+	}
+	c.ID = ksuid.New().String()
+
+	if err := cs.repository.PutCategory(ctx, c); err != nil {
+		return nil, err
+	}
+	cs.forgetCategoryTree()
+	return &c, nil
+}
+
+// UpdateCategory renames a category or changes its description. Its place
+// in the tree is changed with MoveCategory.
+func (cs *catalogService) UpdateCategory(ctx context.Context, c Category) (*Category, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return nil, errs.InvalidArgument("name", "name is required")
+	}
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := tree[c.ID]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+	if tree.nameTaken(existing.ParentID, c.Name, c.ID) {
+		return nil, ErrCategoryExists
+	}
+	updated := *existing
+	updated.Name, updated.Description = c.Name, c.Description
+
+	if err := cs.repository.PutCategory(ctx, updated); err != nil {
+		return nil, err
+	}
+	cs.forgetCategoryTree()
+	return &updated, nil
+}
+
+// MoveCategory puts a category, with its subcategories, under parentID, or
+// at the root when parentID is empty, at position among its new siblings.
+func (cs *catalogService) MoveCategory(ctx context.Context, id, parentID string, position int32) (*Category, error) {
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := tree[id]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+	if _, ok := tree[parentID]; parentID != "" && !ok {
+		return nil, ErrCategoryNotFound
+	}
+	for _, descendant := range tree.descendants(id) {
+		if descendant == parentID {
+			return nil, ErrCategoryCycle
+		}
+	}
+	if tree.nameTaken(parentID, existing.Name, id) {
+		return nil, ErrCategoryExists
+	}
+	moved := *existing
+	moved.ParentID, moved.Position = parentID, position
+
+	if err := cs.repository.PutCategory(ctx, moved); err != nil {
+		return nil, err
+	}
+	cs.forgetCategoryTree()
+
+	// The checks above only saw the tree as it was. A concurrent move, say
+	// of the new parent under this category, may have made a cycle since;
+	// whichever move finishes last sees it and is undone.
+	tree, err = cs.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current, ok := tree[id]; ok && tree.cyclic(id) {
+		restored := *current
+		restored.ParentID, restored.Position = existing.ParentID, existing.Position
+		if err := cs.repository.PutCategory(ctx, restored); err != nil {
+			slog.ErrorContext(ctx, "Error undoing category move", "category_id", id, "err", err)
+		}
+		return nil, ErrCategoryConflict
+	}
+	return &moved, nil
+}
+
+// DeleteCategory removes an empty category. Subcategories have to be moved
+// or deleted, and products relinked, first.
+func (cs *catalogService) DeleteCategory(ctx context.Context, id string) error {
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree[id]; !ok {
+		return ErrCategoryNotFound
+	}
+	if len(tree.descendants(id)) > 1 {
+		return ErrCategoryNotEmpty
+	}
+	products, err := cs.repository.CountProducts(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+	if products > 0 {
+		return ErrCategoryNotEmpty
+	}
+	if err := cs.repository.DeleteCategory(ctx, id); err != nil {
+		return err
+	}
+	cs.forgetCategoryTree()
+	return nil
+}
+
+// GetCategoryTree returns the whole tree, or the subtree under rootID when
+// it is set.
+func (cs *catalogService) GetCategoryTree(ctx context.Context, rootID string) ([]*CategoryNode, error) {
+	tree, err := cs.cachedCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rootID == "" {
+		return tree.nodes(""), nil
+	}
+	root, ok := tree[rootID]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+	return []*CategoryNode{{Category: *root, Children: tree.nodes(rootID)}}, nil
+}
+
+// categoryFilter expands categoryID to itself and its subcategories for
+// product queries. An empty categoryID matches every product.
+func (cs *catalogService) categoryFilter(ctx context.Context, categoryID string) ([]string, error) {
+	if categoryID == "" {
+		return nil, nil
+	}
+	tree, err := cs.cachedCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tree[categoryID]; !ok {
+		return nil, ErrCategoryNotFound
+	}
+	return tree.descendants(categoryID), nil
+}
+
+// checkCategories makes sure products only link to existing categories.
+func (cs *catalogService) checkCategories(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tree, err := cs.loadCategoryTree(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, ok := tree[id]; !ok {
+			return errs.InvalidArgument("categoryIds", "unknown category "+id)
+		}
+	}
+	return nil
+}
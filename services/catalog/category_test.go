@@ -0,0 +1,151 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCategoryTreeTraversal(t *testing.T) {
+	tests := []struct {
+		name        string
+		categories  []Category
+		root        string
+		descendants []string
+		roots       []string
+		cyclic      bool
+	}{
+		{
+			name: "nested",
+			categories: []Category{
+				{ID: "fruit", Name: "Fruit"},
+				{ID: "apples", Name: "Apples", ParentID: "fruit"},
+				{ID: "green", Name: "Green", ParentID: "apples"},
+				{ID: "dairy", Name: "Dairy", Position: -1},
+			},
+			root:        "fruit",
+			descendants: []string{"apples", "fruit", "green"},
+			roots:       []string{"dairy", "fruit"},
+		},
+		{
+			name: "cycle left by concurrent moves",
+			categories: []Category{
+				{ID: "a", Name: "A", ParentID: "b"},
+				{ID: "b", Name: "B", ParentID: "a"},
+				{ID: "c", Name: "C", ParentID: "a"},
+			},
+			root:        "a",
+			descendants: []string{"a", "b", "c"},
+			roots:       []string{},
+			cyclic:      true,
+		},
+		{
+			name: "category listed as its own parent",
+			categories: []Category{
+				{ID: "a", Name: "A", ParentID: "a"},
+			},
+			root:        "a",
+			descendants: []string{"a"},
+			roots:       []string{},
+			cyclic:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := categoryTree{}
+			for i := range tt.categories {
+				tree[tt.categories[i].ID] = &tt.categories[i]
+			}
+
+			descendants := tree.descendants(tt.root)
+			slices.Sort(descendants)
+			if !slices.Equal(descendants, tt.descendants) {
+				t.Errorf("descendants(%q) = %v, want %v", tt.root, descendants, tt.descendants)
+			}
+
+			if got := tree.cyclic(tt.root); got != tt.cyclic {
+				t.Errorf("cyclic(%q) = %v, want %v", tt.root, got, tt.cyclic)
+			}
+
+			roots := []string{}
+			for _, n := range tree.nodes("") {
+				roots = append(roots, n.ID)
+			}
+			if !slices.Equal(roots, tt.roots) {
+				t.Errorf("root nodes = %v, want %v", roots, tt.roots)
+			}
+			// Every subtree ends, visiting each category at most once
+			seen := map[string]int{}
+			var walk func([]*CategoryNode)
+			walk = func(nodes []*CategoryNode) {
+				for _, n := range nodes {
+					seen[n.ID]++
+					walk(n.Children)
+				}
+			}
+			walk(tree.nodes(tt.root))
+			for id, n := range seen {
+				if n > 1 {
+					t.Errorf("category %s appears %d times under %s", id, n, tt.root)
+				}
+			}
+		})
+	}
+}
+
+// fakeCategories stores categories for the service tests. beforePut, when
+// set, runs once ahead of the next write, standing in for a concurrent change.
+type fakeCategories struct {
+	Repository
+	categories map[string]Category
+	beforePut  func()
+}
+
+func (r *fakeCategories) PutCategory(ctx context.Context, c Category) error {
+	if f := r.beforePut; f != nil {
+		r.beforePut = nil
+		f()
+	}
+	r.categories[c.ID] = c
+	return nil
+}
+
+func (r *fakeCategories) ListCategories(ctx context.Context) ([]Category, error) {
+	categories := []Category{}
+	for _, c := range r.categories {
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func TestMoveCategoryConcurrently(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeCategories{categories: map[string]Category{
+		"a": {ID: "a", Name: "A"},
+		"b": {ID: "b", Name: "B"},
+	}}
+	cs := &catalogService{repository: r}
+
+	// b is moved under a after this move checked the tree but before it
+	// wrote, so moving a under b would close a cycle
+	r.beforePut = func() {
+		r.categories["b"] = Category{ID: "b", Name: "B", ParentID: "a"}
+	}
+	if _, err := cs.MoveCategory(ctx, "a", "b", 0); !errors.Is(err, ErrCategoryConflict) {
+		t.Fatalf("err = %v, want %v", err, ErrCategoryConflict)
+	}
+	if a := r.categories["a"]; a.ParentID != "" {
+		t.Errorf("a is still under %q", a.ParentID)
+	}
+
+	// Without the concurrent move it goes through
+	r.categories["b"] = Category{ID: "b", Name: "B"}
+	moved, err := cs.MoveCategory(ctx, "a", "b", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ParentID != "b" || r.categories["a"].ParentID != "b" || r.categories["a"].Position != 2 {
+		t.Errorf("moved = %+v, stored %+v", moved, r.categories["a"])
+	}
+}
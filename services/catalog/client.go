@@ -3,6 +3,11 @@ package catalog
 import (
 	"context"
 
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/catalog/pb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -13,8 +18,14 @@ type Client struct {
 	service pb.CatalogServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewClient connects to the catalog service. Services pass
+// auth.ServiceCredentials in opts to reserve and release stock.
+func NewClient(url string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(url, append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(), logging.UnaryClientInterceptor(), auth.UnaryClientInterceptor()),
+	}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,21 +37,27 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
-func (c *Client) PostProduct(ctx context.Context, name, description string, price float64) (*Product, error) {
-	r, err := c.service.PostProduct(ctx, &pb.PostProductRequest{
-		Name:        name,
-		Description: description,
-		Price:       price,
-	})
+// Ping checks the catalog service through its gRPC health service.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.GRPC(c.conn, pb.CatalogService_ServiceDesc.ServiceName)(ctx)
+}
+
+func (c *Client) PostProduct(ctx context.Context, p Product) (*Product, error) {
+	r, err := c.service.PostProduct(ctx, &pb.PostProductRequest{Product: productToProto(&p)})
+	if err != nil {
+		return nil, err
+	}
+	product := productFromProto(r.Product)
+	return &product, nil
+}
+
+func (c *Client) UpdateProduct(ctx context.Context, id string, categoryIDs []string, active bool) (*Product, error) {
+	r, err := c.service.UpdateProduct(ctx, &pb.UpdateProductRequest{Id: id, CategoryIds: categoryIDs, Active: active})
 	if err != nil {
 		return nil, err
 	}
-	return &Product{
-		ID:          r.Product.Id,
-		Name:        r.Product.Name,
-		Description: r.Product.Description,
-		Price:       r.Product.Price,
-	}, nil
+	product := productFromProto(r.Product)
+	return &product, nil
 }
 
 func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
@@ -48,28 +65,114 @@ func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Product{
-		ID:          r.Product.Id,
-		Name:        r.Product.Name,
-		Description: r.Product.Description,
-		Price:       r.Product.Price,
-	}, nil
+	product := productFromProto(r.Product)
+	return &product, nil
 }
 
-func (c *Client) GetProducts(ctx context.Context, query string, ids []string, skip uint64, take uint64) (*[]Product, error) {
-	res, err := c.service.GetProducts(ctx, &pb.GetProductsRequest{Query: query, Ids: ids, Skip: skip, Take: take})
+// GetProducts fetches the products with ids, or searches for query, or lists
+// products. Search and listing are limited to categoryID and its
+// subcategories when it is set.
+func (c *Client) GetProducts(ctx context.Context, query string, categoryID string, ids []string, skip uint64, take uint64) (*[]Product, error) {
+	res, err := c.service.GetProducts(ctx, &pb.GetProductsRequest{Query: query, CategoryId: categoryID, Ids: ids, Skip: skip, Take: take})
 	if err != nil {
 		return nil, err
 	}
 
 	products := []Product{}
-	for _, acc := range res.Products {
-		products = append(products, Product{
-			ID:          acc.Id,
-			Name:        acc.Name,
-			Description: acc.Description,
-			Price:       acc.Price,
-		})
+	for _, p := range res.Products {
+		products = append(products, productFromProto(p))
 	}
 	return &products, nil
 }
+
+func (c *Client) CreateCategory(ctx context.Context, category Category) (*Category, error) {
+	res, err := c.service.CreateCategory(ctx, &pb.CategoryRequest{Category: categoryToProto(&category)})
+	if err != nil {
+		return nil, err
+	}
+	created := categoryFromProto(res.Category)
+	return &created, nil
+}
+
+func (c *Client) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
+	res, err := c.service.UpdateCategory(ctx, &pb.CategoryRequest{Category: categoryToProto(&category)})
+	if err != nil {
+		return nil, err
+	}
+	updated := categoryFromProto(res.Category)
+	return &updated, nil
+}
+
+func (c *Client) MoveCategory(ctx context.Context, id, parentID string, position int32) (*Category, error) {
+	res, err := c.service.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: id, ParentId: parentID, Position: position})
+	if err != nil {
+		return nil, err
+	}
+	moved := categoryFromProto(res.Category)
+	return &moved, nil
+}
+
+func (c *Client) DeleteCategory(ctx context.Context, id string) error {
+	_, err := c.service.DeleteCategory(ctx, &pb.DeleteCategoryRequest{Id: id})
+	return err
+}
+
+func (c *Client) GetCategoryTree(ctx context.Context, rootID string) ([]*CategoryNode, error) {
+	res, err := c.service.GetCategoryTree(ctx, &pb.GetCategoryTreeRequest{RootId: rootID})
+	if err != nil {
+		return nil, err
+	}
+	return categoryNodesFromProto(res.Categories), nil
+}
+
+func (c *Client) AdjustStock(ctx context.Context, storeID string, items []StockDelta, reason, note, reference, idempotencyKey string) ([]Stock, error) {
+	res, err := c.service.AdjustStock(ctx, &pb.AdjustStockRequest{
+		StoreId:        storeID,
+		Items:          stockDeltasToProto(items),
+		Reason:         reason,
+		Note:           note,
+		Reference:      reference,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stockFromProto(res.Stock), nil
+}
+
+// ReserveStock takes the ordered quantities, given as positive deltas, out
+// of a store's stock for an order.
+func (c *Client) ReserveStock(ctx context.Context, storeID, orderID string, items []StockDelta) ([]Stock, error) {
+	res, err := c.service.ReserveStock(ctx, &pb.StockReservationRequest{StoreId: storeID, OrderId: orderID, Items: stockDeltasToProto(items)})
+	if err != nil {
+		return nil, err
+	}
+	return stockFromProto(res.Stock), nil
+}
+
+// ReleaseStock puts back what ReserveStock took for an order.
+func (c *Client) ReleaseStock(ctx context.Context, storeID, orderID string) ([]Stock, error) {
+	res, err := c.service.ReleaseStock(ctx, &pb.ReleaseStockRequest{StoreId: storeID, OrderId: orderID})
+	if err != nil {
+		return nil, err
+	}
+	return stockFromProto(res.Stock), nil
+}
+
+// GetStock returns the stock of each product at storeID, or across stores
+// when storeID is empty, in the order of productIDs.
+func (c *Client) GetStock(ctx context.Context, storeID string, productIDs []string) ([]Stock, error) {
+	res, err := c.service.GetStock(ctx, &pb.GetStockRequest{StoreId: storeID, ProductIds: productIDs})
+	if err != nil {
+		return nil, err
+	}
+	return stockFromProto(res.Stock), nil
+}
+
+func (c *Client) ListLowStock(ctx context.Context, storeID string, threshold int32, skip uint64, take uint64) ([]Stock, error) {
+	res, err := c.service.ListLowStock(ctx, &pb.ListLowStockRequest{StoreId: storeID, Threshold: threshold, Skip: skip, Take: take})
+	if err != nil {
+		return nil, err
+	}
+	return stockFromProto(res.Stock), nil
+}
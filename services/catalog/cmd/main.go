@@ -1,39 +1,97 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/migrate"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+	"github.com/theshubhamy/microGo/services/account"
 	"github.com/theshubhamy/microGo/services/catalog"
 	"github.com/tinrab/retry"
 )
 
-type Config struct {
-	DATABASE_URL string `envconfig:"DATABASE_URL"`
-}
-
 func main() {
-	var config Config
+	config, err := catalog.LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		logging.Fatal("Failed to load config", "err", err)
+	}
+	logging.Setup("catalog", config.Logging)
+	flushTraces, err := tracing.Setup(context.Background(), "catalog", config.Options)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "err", err)
+	}
+	defer flushTraces()
+	// Needed to verify access tokens on role-protected RPCs
+	verifier := account.NewJWKSVerifier(config.JWKSURL, config.JWKSRefresh)
 
-	err := envconfig.Process("", &config)
+	var migrator *migrate.Migrator
+	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
+		migrator, err = catalog.NewMigrator(config.InventoryDatabaseURL)
+		if err != nil {
+			slog.Error("Failed to connect to Postgres", "err", err)
+		}
+		return
+	})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate.Command(context.Background(), migrator, os.Args[2:], os.Stdout)
+		migrator.Close()
+		if err != nil {
+			logging.Fatal("Migration failed", "err", err)
+		}
+		return
+	}
+	applied, err := migrator.Up(context.Background())
+	migrator.Close()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to migrate database", "err", err)
+	}
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
 	}
 
 	var r catalog.Repository
 
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
-		r, err = catalog.NewElasticRepository(config.DATABASE_URL)
+		r, err = catalog.NewElasticRepository(config.DatabaseURL)
 		if err != nil {
-			log.Println("Elastic Search db error")
-			log.Println(err)
+			slog.Error("Failed to connect to Elasticsearch", "err", err)
+			return
 		}
-		log.Println("Elastic Search db conected")
+		slog.Info("Connected to Elasticsearch")
 		return
 	})
 	defer r.Close()
-	log.Println("Server running at 8080 ...")
-	s := catalog.NewService(r)
-	log.Fatal(catalog.ListenGrpcServer(s, 8080))
+
+	var inventory catalog.InventoryRepository
+	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
+		inventory, err = catalog.NewPostgresInventoryRepository(config.InventoryDatabaseURL)
+		if err != nil {
+			slog.Error("Failed to connect to Postgres", "err", err)
+		}
+		return
+	})
+	defer inventory.Close()
+
+	slog.Info("Server running", "port", config.Port)
+	s := catalog.NewService(r, inventory, config.LowStockThreshold)
+	checks := health.Checks{"elasticsearch": r.Ping, "postgres": inventory.Ping}
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	go func() {
+		if err := metrics.Serve(ctx, config.MetricsPort, config.ShutdownTimeout); err != nil {
+			logging.Fatal("Metrics server failed", "err", err)
+		}
+	}()
+	if err := catalog.ListenGrpcServer(ctx, s, verifier.VerifyAccessToken, config.ServiceKey, checks, config.Port, config.DrainDelay, config.ShutdownTimeout); err != nil {
+		logging.Fatal("Server failed", "err", err)
+	}
+	slog.Info("Server stopped")
 }
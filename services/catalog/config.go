@@ -0,0 +1,85 @@
+package catalog
+
+import (
+	"errors"
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/config"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+)
+
+// Config is the catalog service configuration.
+type Config struct {
+	Port        int    `envconfig:"PORT" yaml:"port"`
+	MetricsPort int    `envconfig:"METRICS_PORT" yaml:"metrics_port"`
+	DatabaseURL string `envconfig:"DATABASE_URL" yaml:"database_url"`
+	// Stock levels and their audit trail live in Postgres
+	InventoryDatabaseURL string `envconfig:"INVENTORY_DATABASE_URL" yaml:"inventory_database_url"`
+	// Default threshold for ListLowStock
+	LowStockThreshold int32 `envconfig:"LOW_STOCK_THRESHOLD" yaml:"low_stock_threshold"`
+	// Shared with the services allowed to reserve and release stock
+	ServiceKey string `envconfig:"SERVICE_KEY" yaml:"service_key"`
+	// Access tokens are verified against the account service's public keys
+	JWKSURL     string        `envconfig:"JWKS_URL" yaml:"jwks_url"`
+	JWKSRefresh time.Duration `envconfig:"JWKS_REFRESH" yaml:"jwks_refresh"`
+	// How long to keep serving after SIGTERM while reporting not ready, so
+	// load balancers stop sending new requests
+	DrainDelay time.Duration `envconfig:"DRAIN_DELAY" yaml:"drain_delay"`
+	// How long in-flight requests may run on after SIGTERM
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	tracing.Options `yaml:",inline"`
+	Logging         logging.Options `yaml:",inline"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port:              8080,
+		MetricsPort:       9090,
+		JWKSURL:           "http://account:8081/.well-known/jwks.json",
+		JWKSRefresh:       10 * time.Minute,
+		LowStockThreshold: 5,
+		DrainDelay:        5 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+	}
+}
+
+// LoadConfig overlays the YAML file at path, if any, and the environment on
+// DefaultConfig. Both the Elasticsearch and the inventory database URLs must
+// be set.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	err := config.Load(path, &cfg)
+	return cfg, err
+}
+
+func (c *Config) Validate() error {
+	err := config.Required(map[string]string{
+		"DATABASE_URL":           c.DatabaseURL,
+		"INVENTORY_DATABASE_URL": c.InventoryDatabaseURL,
+		"JWKS_URL":               c.JWKSURL,
+		"SERVICE_KEY":            c.ServiceKey,
+	})
+	if err != nil {
+		return err
+	}
+	if len(c.ServiceKey) < 32 {
+		return errors.New("SERVICE_KEY must be at least 32 characters")
+	}
+	if c.JWKSRefresh <= 0 {
+		return errors.New("JWKS_REFRESH must be positive")
+	}
+	if c.LowStockThreshold < 0 {
+		return errors.New("LOW_STOCK_THRESHOLD must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.DrainDelay < 0 {
+		return errors.New("DRAIN_DELAY must not be negative")
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	return c.Options.Validate()
+}
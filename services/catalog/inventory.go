@@ -0,0 +1,194 @@
+package catalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/errs"
+)
+
+// Reasons for a stock adjustment. Restocks, returns and releases add stock,
+// sales and damage remove it and corrections go either way.
+const (
+	StockRestock    = "restock"
+	StockSale       = "sale"
+	StockReturn     = "return"
+	StockDamage     = "damage"
+	StockCorrection = "correction"
+	// StockRelease puts back stock reserved for an order that fell through
+	StockRelease = "release"
+)
+
+var (
+	ErrStoreRequired            = errs.InvalidArgument("storeId", "store id is required")
+	ErrInvalidStockReason       = errs.InvalidArgument("reason", "reason must be one of restock, sale, return, damage, correction or release")
+	ErrInvalidStockDelta        = errs.InvalidArgument("items", "each item needs a product and a non-zero change in the direction of the reason, and may only appear once")
+	ErrStockIdempotencyConflict = errs.Conflict("idempotency key was already used for a different stock adjustment")
+)
+
+// Stock is the quantity of a product on hand at a dark store. Totals across
+// stores have no StoreID.
+type Stock struct {
+	ProductID string    `json:"productId"`
+	StoreID   string    `json:"storeId"`
+	Quantity  int32     `json:"quantity"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// StockDelta changes the stock of a product by Delta.
+type StockDelta struct {
+	ProductID string `json:"productId"`
+	Delta     int32  `json:"delta"`
+}
+
+// StockAdjustment is an entry of the stock audit trail. Adjustments made by
+// one call share a BatchID and IdempotencyKey.
+type StockAdjustment struct {
+	ID             string
+	BatchID        string
+	ProductID      string
+	StoreID        string
+	Delta          int32
+	QuantityAfter  int32
+	Reason         string
+	Reference      string
+	Note           string
+	ActorID        string
+	IdempotencyKey string
+	CreatedAt      time.Time
+}
+
+func insufficientStock(productID string) error {
+	return errs.FailedPrecondition("insufficient stock for product " + productID)
+}
+
+// AdjustStock applies items to the stock of storeID, all or nothing, and
+// records each change with the caller as actor. No level may go below zero.
+// Retrying with the same idempotency key returns the current levels instead
+// of applying the changes twice.
+func (cs *catalogService) AdjustStock(ctx context.Context, storeID string, items []StockDelta, reason, note, reference, idempotencyKey string) ([]Stock, error) {
+	if storeID == "" {
+		return nil, ErrStoreRequired
+	}
+	sign := 0
+	switch reason {
+	case StockRestock, StockReturn, StockRelease:
+		sign = 1
+	case StockSale, StockDamage:
+		sign = -1
+	case StockCorrection:
+	default:
+		return nil, ErrInvalidStockReason
+	}
+	if len(items) == 0 {
+		return nil, ErrInvalidStockDelta
+	}
+	seen := map[string]bool{}
+	for _, item := range items {
+		if item.ProductID == "" || item.Delta == 0 || seen[item.ProductID] ||
+			(sign > 0 && item.Delta < 0) || (sign < 0 && item.Delta > 0) {
+			return nil, ErrInvalidStockDelta
+		}
+		seen[item.ProductID] = true
+	}
+
+	actorID := ""
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		actorID = claims.UserID
+	}
+	batchID := ksuid.New().String()
+	adjustments := []StockAdjustment{}
+	for _, item := range items {
+		adjustments = append(adjustments, StockAdjustment{
+			ID:             ksuid.New().String(),
+			BatchID:        batchID,
+			ProductID:      item.ProductID,
+			StoreID:        storeID,
+			Delta:          item.Delta,
+			Reason:         reason,
+			Reference:      reference,
+			Note:           note,
+			ActorID:        actorID,
+			IdempotencyKey: idempotencyKey,
+		})
+	}
+	return cs.inventory.AdjustStock(ctx, adjustments)
+}
+
+// reservationKey is the idempotency key ReserveStock records an order's
+// reservation under.
+func reservationKey(orderID string) string {
+	return "order:" + orderID
+}
+
+// ReserveStock takes the quantities in items, given as positive deltas, out
+// of the stock of storeID for an order. The order id keys the reservation,
+// so retrying it is safe.
+func (cs *catalogService) ReserveStock(ctx context.Context, storeID, orderID string, items []StockDelta) ([]Stock, error) {
+	sale := []StockDelta{}
+	for _, item := range items {
+		sale = append(sale, StockDelta{ProductID: item.ProductID, Delta: -item.Delta})
+	}
+	return cs.AdjustStock(ctx, storeID, sale, StockSale, "", "order:"+orderID, reservationKey(orderID))
+}
+
+// ReleaseStock puts back what ReserveStock took for an order that couldn't
+// be placed, as recorded at storeID. It does nothing when nothing was
+// reserved there.
+func (cs *catalogService) ReleaseStock(ctx context.Context, storeID, orderID string) ([]Stock, error) {
+	if storeID == "" {
+		return nil, ErrStoreRequired
+	}
+	reserved, err := cs.inventory.BatchDeltas(ctx, storeID, reservationKey(orderID))
+	if err != nil {
+		return nil, err
+	}
+	if len(reserved) == 0 {
+		return []Stock{}, nil
+	}
+	items := []StockDelta{}
+	for productID, delta := range reserved {
+		items = append(items, StockDelta{ProductID: productID, Delta: -delta})
+	}
+	return cs.AdjustStock(ctx, storeID, items, StockRelease, "", "order:"+orderID, "release:order:"+orderID)
+}
+
+// GetStock returns the stock of each product at storeID, or its total across
+// stores when storeID is empty. Products never stocked have a zero quantity.
+func (cs *catalogService) GetStock(ctx context.Context, storeID string, productIDs []string) ([]Stock, error) {
+	if len(productIDs) == 0 {
+		return []Stock{}, nil
+	}
+	found, err := cs.inventory.GetStock(ctx, storeID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := map[string]Stock{}
+	for _, s := range found {
+		byProduct[s.ProductID] = s
+	}
+	stock := []Stock{}
+	for _, id := range productIDs {
+		s, ok := byProduct[id]
+		if !ok {
+			s = Stock{ProductID: id, StoreID: storeID}
+		}
+		stock = append(stock, s)
+	}
+	return stock, nil
+}
+
+// ListLowStock lists stock levels at or below threshold, lowest first, at
+// storeID or at every store when it is empty. A zero threshold means the
+// configured default. Products never stocked at a store aren't listed.
+func (cs *catalogService) ListLowStock(ctx context.Context, storeID string, threshold int32, skip uint64, take uint64) ([]Stock, error) {
+	if take == 0 || take > 100 {
+		take = 100
+	}
+	if threshold <= 0 {
+		threshold = cs.lowStockThreshold
+	}
+	return cs.inventory.ListLowStock(ctx, storeID, threshold, skip, take)
+}
@@ -0,0 +1,229 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/lib/pq"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+)
+
+// InventoryRepository keeps stock levels and their audit trail in Postgres,
+// next to the products in Elasticsearch: stock changes need transactions.
+type InventoryRepository interface {
+	Close() error
+	Ping(ctx context.Context) error
+	// AdjustStock applies a batch of adjustments to one store, all or none
+	AdjustStock(ctx context.Context, batch []StockAdjustment) ([]Stock, error)
+	// BatchDeltas returns the deltas by product recorded at storeID under
+	// idempotencyKey, empty when there are none
+	BatchDeltas(ctx context.Context, storeID, idempotencyKey string) (map[string]int32, error)
+	GetStock(ctx context.Context, storeID string, productIDs []string) ([]Stock, error)
+	ListLowStock(ctx context.Context, storeID string, threshold int32, skip uint64, take uint64) ([]Stock, error)
+}
+
+type postgresInventoryRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresInventoryRepository(url string) (InventoryRepository, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+	return &postgresInventoryRepository{db}, nil
+}
+
+func (r *postgresInventoryRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *postgresInventoryRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// AdjustStock locks the stock rows of the batch, in product order so that
+// concurrent batches can't deadlock, before checking the idempotency key and
+// the new levels. A batch that would take any level below zero fails whole.
+func (r *postgresInventoryRepository) AdjustStock(ctx context.Context, batch []StockAdjustment) (_ []Stock, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "AdjustStock")
+	defer func() { done(err) }()
+	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	sorted := append([]StockAdjustment{}, batch...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ProductID < sorted[j].ProductID })
+	current := map[string]Stock{}
+	for _, a := range sorted {
+		if _, err = txn.ExecContext(ctx,
+			"INSERT INTO stock(product_id, store_id, quantity) VALUES ($1, $2, 0) ON CONFLICT DO NOTHING",
+			a.ProductID, a.StoreID,
+		); err != nil {
+			return nil, err
+		}
+		s := Stock{ProductID: a.ProductID, StoreID: a.StoreID}
+		if err = txn.QueryRowContext(ctx,
+			"SELECT quantity, updated_at FROM stock WHERE product_id = $1 AND store_id = $2 FOR UPDATE",
+			a.ProductID, a.StoreID,
+		).Scan(&s.Quantity, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		current[a.ProductID] = s
+	}
+
+	if key := batch[0].IdempotencyKey; key != "" {
+		var previous map[string]int32
+		if previous, err = batchDeltas(ctx, txn, batch[0].StoreID, key); err != nil {
+			return nil, err
+		}
+		if len(previous) > 0 {
+			if !sameDeltas(previous, batch) {
+				return nil, ErrStockIdempotencyConflict
+			}
+			stock := []Stock{}
+			for _, a := range batch {
+				stock = append(stock, current[a.ProductID])
+			}
+			return stock, nil
+		}
+	}
+
+	for _, a := range sorted {
+		s := current[a.ProductID]
+		s.Quantity += a.Delta
+		if s.Quantity < 0 {
+			return nil, insufficientStock(a.ProductID)
+		}
+		if err = txn.QueryRowContext(ctx,
+			"UPDATE stock SET quantity = $3, updated_at = now() WHERE product_id = $1 AND store_id = $2 RETURNING updated_at",
+			a.ProductID, a.StoreID, s.Quantity,
+		).Scan(&s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if _, err = txn.ExecContext(ctx,
+			`INSERT INTO stock_adjustments(id, batch_id, product_id, store_id, delta, quantity_after, reason, reference, note, actor_id, idempotency_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''))`,
+			a.ID, a.BatchID, a.ProductID, a.StoreID, a.Delta, s.Quantity, a.Reason, a.Reference, a.Note, a.ActorID, a.IdempotencyKey,
+		); err != nil {
+			return nil, err
+		}
+		current[a.ProductID] = s
+	}
+
+	stock := []Stock{}
+	for _, a := range batch {
+		stock = append(stock, current[a.ProductID])
+	}
+	return stock, nil
+}
+
+func (r *postgresInventoryRepository) BatchDeltas(ctx context.Context, storeID, idempotencyKey string) (_ map[string]int32, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "BatchDeltas")
+	defer func() { done(err) }()
+	return batchDeltas(ctx, r.db, storeID, idempotencyKey)
+}
+
+// querier is what batchDeltas needs of a *sql.DB or *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// batchDeltas returns the deltas by product already recorded under an
+// idempotency key at a store.
+func batchDeltas(ctx context.Context, q querier, storeID, key string) (map[string]int32, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT product_id, delta FROM stock_adjustments WHERE store_id = $1 AND idempotency_key = $2",
+		storeID, key,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deltas := map[string]int32{}
+	for rows.Next() {
+		var productID string
+		var delta int32
+		if err := rows.Scan(&productID, &delta); err != nil {
+			return nil, err
+		}
+		deltas[productID] = delta
+	}
+	return deltas, rows.Err()
+}
+
+func sameDeltas(previous map[string]int32, batch []StockAdjustment) bool {
+	if len(previous) != len(batch) {
+		return false
+	}
+	for _, a := range batch {
+		if delta, ok := previous[a.ProductID]; !ok || delta != a.Delta {
+			return false
+		}
+	}
+	return true
+}
+
+// GetStock returns the stock rows of productIDs at storeID or, when storeID
+// is empty, their totals across stores.
+func (r *postgresInventoryRepository) GetStock(ctx context.Context, storeID string, productIDs []string) (_ []Stock, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetStock")
+	defer func() { done(err) }()
+	var rows *sql.Rows
+	if storeID == "" {
+		rows, err = r.db.QueryContext(ctx,
+			"SELECT product_id, '', SUM(quantity)::INTEGER, MAX(updated_at) FROM stock WHERE product_id = ANY($1) GROUP BY product_id",
+			pq.Array(productIDs),
+		)
+	} else {
+		rows, err = r.db.QueryContext(ctx,
+			"SELECT product_id, store_id, quantity, updated_at FROM stock WHERE store_id = $1 AND product_id = ANY($2)",
+			storeID, pq.Array(productIDs),
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanStock(rows)
+}
+
+func (r *postgresInventoryRepository) ListLowStock(ctx context.Context, storeID string, threshold int32, skip uint64, take uint64) (_ []Stock, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "ListLowStock")
+	defer func() { done(err) }()
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT product_id, store_id, quantity, updated_at FROM stock
+		WHERE quantity <= $1 AND ($2 = '' OR store_id = $2)
+		ORDER BY quantity, store_id, product_id OFFSET $3 LIMIT $4`,
+		threshold, storeID, skip, take,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanStock(rows)
+}
+
+func scanStock(rows *sql.Rows) ([]Stock, error) {
+	defer rows.Close()
+	stock := []Stock{}
+	for rows.Next() {
+		s := Stock{}
+		if err := rows.Scan(&s.ProductID, &s.StoreID, &s.Quantity, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
@@ -0,0 +1,141 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeInventory records adjustment batches, keyed by store and idempotency
+// key like the Postgres audit trail.
+type fakeInventory struct {
+	InventoryRepository
+	batches map[string][]StockAdjustment
+}
+
+func (r *fakeInventory) AdjustStock(ctx context.Context, batch []StockAdjustment) ([]Stock, error) {
+	key := batch[0].StoreID + "/" + batch[0].IdempotencyKey
+	if _, ok := r.batches[key]; !ok {
+		r.batches[key] = batch
+	}
+	stock := []Stock{}
+	for _, a := range batch {
+		stock = append(stock, Stock{ProductID: a.ProductID, StoreID: a.StoreID})
+	}
+	return stock, nil
+}
+
+func (r *fakeInventory) BatchDeltas(ctx context.Context, storeID, idempotencyKey string) (map[string]int32, error) {
+	deltas := map[string]int32{}
+	for _, a := range r.batches[storeID+"/"+idempotencyKey] {
+		deltas[a.ProductID] = a.Delta
+	}
+	return deltas, nil
+}
+
+func TestAdjustStockValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   string
+		reason  string
+		items   []StockDelta
+		wantErr error
+	}{
+		{name: "restock", store: "s1", reason: StockRestock, items: []StockDelta{{"p1", 5}}},
+		{name: "sale", store: "s1", reason: StockSale, items: []StockDelta{{"p1", -2}, {"p2", -1}}},
+		{name: "correction either way", store: "s1", reason: StockCorrection, items: []StockDelta{{"p1", -2}, {"p2", 3}}},
+		{name: "no store", reason: StockRestock, items: []StockDelta{{"p1", 5}}, wantErr: ErrStoreRequired},
+		{name: "unknown reason", store: "s1", reason: "gift", items: []StockDelta{{"p1", 5}}, wantErr: ErrInvalidStockReason},
+		{name: "no items", store: "s1", reason: StockRestock, wantErr: ErrInvalidStockDelta},
+		{name: "restock removing stock", store: "s1", reason: StockRestock, items: []StockDelta{{"p1", -5}}, wantErr: ErrInvalidStockDelta},
+		{name: "damage adding stock", store: "s1", reason: StockDamage, items: []StockDelta{{"p1", 1}}, wantErr: ErrInvalidStockDelta},
+		{name: "zero delta", store: "s1", reason: StockCorrection, items: []StockDelta{{"p1", 0}}, wantErr: ErrInvalidStockDelta},
+		{name: "missing product", store: "s1", reason: StockRestock, items: []StockDelta{{"", 1}}, wantErr: ErrInvalidStockDelta},
+		{name: "product twice", store: "s1", reason: StockRestock, items: []StockDelta{{"p1", 1}, {"p1", 2}}, wantErr: ErrInvalidStockDelta},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := &fakeInventory{batches: map[string][]StockAdjustment{}}
+			cs := &catalogService{inventory: inventory}
+
+			_, err := cs.AdjustStock(context.Background(), tt.store, tt.items, tt.reason, "", "", "key")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if recorded := len(inventory.batches) > 0; recorded != (tt.wantErr == nil) {
+				t.Errorf("recorded a batch: %v", recorded)
+			}
+		})
+	}
+}
+
+func TestReserveAndReleaseStock(t *testing.T) {
+	ctx := context.Background()
+	inventory := &fakeInventory{batches: map[string][]StockAdjustment{}}
+	cs := &catalogService{inventory: inventory}
+
+	// Nothing reserved yet, so nothing to put back
+	stock, err := cs.ReleaseStock(ctx, "s1", "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stock) != 0 || len(inventory.batches) != 0 {
+		t.Fatalf("released %v before any reservation", stock)
+	}
+
+	if _, err := cs.ReserveStock(ctx, "s1", "o1", []StockDelta{{"p1", 2}, {"p2", 1}}); err != nil {
+		t.Fatal(err)
+	}
+	reservation := inventory.batches["s1/order:o1"]
+	if len(reservation) != 2 || reservation[0].Reason != StockSale || reservation[0].Delta != -2 || reservation[1].Delta != -1 {
+		t.Fatalf("reservation = %+v", reservation)
+	}
+
+	// Released at another store, the order has nothing to put back
+	if _, err := cs.ReleaseStock(ctx, "s2", "o1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inventory.batches["s2/release:order:o1"]; ok {
+		t.Error("released stock at a store the order didn't reserve at")
+	}
+
+	if _, err := cs.ReleaseStock(ctx, "s1", "o1"); err != nil {
+		t.Fatal(err)
+	}
+	release := map[string]int32{}
+	for _, a := range inventory.batches["s1/release:order:o1"] {
+		if a.Reason != StockRelease || a.Reference != "order:o1" {
+			t.Errorf("release adjustment %+v", a)
+		}
+		release[a.ProductID] = a.Delta
+	}
+	if len(release) != 2 || release["p1"] != 2 || release["p2"] != 1 {
+		t.Errorf("released %v, want exactly the reserved p1:2 p2:1", release)
+	}
+
+	if _, err := cs.ReleaseStock(ctx, "", "o1"); !errors.Is(err, ErrStoreRequired) {
+		t.Errorf("release without store: err = %v", err)
+	}
+}
+
+func TestSameDeltas(t *testing.T) {
+	batch := []StockAdjustment{{ProductID: "p1", Delta: -2}, {ProductID: "p2", Delta: -1}}
+	tests := []struct {
+		name     string
+		previous map[string]int32
+		want     bool
+	}{
+		{name: "same", previous: map[string]int32{"p1": -2, "p2": -1}, want: true},
+		{name: "different quantity", previous: map[string]int32{"p1": -3, "p2": -1}},
+		{name: "different product", previous: map[string]int32{"p1": -2, "p3": -1}},
+		{name: "fewer products", previous: map[string]int32{"p1": -2}},
+		{name: "more products", previous: map[string]int32{"p1": -2, "p2": -1, "p3": -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameDeltas(tt.previous, batch); got != tt.want {
+				t.Errorf("sameDeltas = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_adjustments;
+DROP FUNCTION IF EXISTS reject_stock_adjustment_change();
+DROP TABLE IF EXISTS stock;
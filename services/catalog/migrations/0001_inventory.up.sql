@@ -0,0 +1,42 @@
+-- Stock on hand per product and dark store, in packs of the product. Store
+-- ids are opaque to the catalog.
+CREATE TABLE IF NOT EXISTS stock (
+  product_id CHAR(27) NOT NULL,
+  store_id VARCHAR(64) NOT NULL,
+  quantity INTEGER NOT NULL CHECK (quantity >= 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (product_id, store_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_store_quantity ON stock (store_id, quantity);
+
+-- Audit trail of every change to a stock level. One AdjustStock call is one
+-- batch, and a retried call is recognised by its idempotency key.
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+  id CHAR(27) PRIMARY KEY,
+  batch_id CHAR(27) NOT NULL,
+  product_id CHAR(27) NOT NULL,
+  store_id VARCHAR(64) NOT NULL,
+  delta INTEGER NOT NULL CHECK (delta <> 0),
+  quantity_after INTEGER NOT NULL CHECK (quantity_after >= 0),
+  reason VARCHAR(16) NOT NULL CHECK (reason IN ('restock', 'sale', 'return', 'damage', 'correction', 'release')),
+  reference VARCHAR(255) NOT NULL DEFAULT '',
+  note TEXT NOT NULL DEFAULT '',
+  actor_id VARCHAR(27) NOT NULL DEFAULT '',
+  idempotency_key VARCHAR(255),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_product ON stock_adjustments (product_id, store_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_adjustments_idempotency
+  ON stock_adjustments (store_id, idempotency_key, product_id) WHERE idempotency_key IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION reject_stock_adjustment_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'stock adjustments are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_adjustments_immutable ON stock_adjustments;
+CREATE TRIGGER stock_adjustments_immutable BEFORE UPDATE OR DELETE ON stock_adjustments
+  FOR EACH ROW EXECUTE PROCEDURE reject_stock_adjustment_change();
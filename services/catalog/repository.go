@@ -4,18 +4,33 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
 
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	elastic "gopkg.in/olivere/elastic.v5"
 )
 
 type Repository interface {
 	Close()
+	Ping(ctx context.Context) error
 	PutProduct(ctx context.Context, p Product) error
 	GetProductbyId(ctx context.Context, id string) (*Product, error)
-	ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error)
+	// ListProducts and SearchProduct only return products linked to one of
+	// categoryIDs when it isn't empty
+	ListProducts(ctx context.Context, categoryIDs []string, skip uint64, take uint64) ([]Product, error)
 	ListProductsWithIds(ctx context.Context, ids []string) ([]Product, error)
-	SearchProduct(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error)
+	SearchProduct(ctx context.Context, query string, categoryIDs []string, skip uint64, take uint64) ([]Product, error)
+	// CountProducts counts products linked to any of categoryIDs, including
+	// inactive ones
+	CountProducts(ctx context.Context, categoryIDs []string) (int64, error)
+	PutCategory(ctx context.Context, c Category) error
+	ListCategories(ctx context.Context) ([]Category, error)
+	DeleteCategory(ctx context.Context, id string) error
 }
 
 type elasticRepository struct {
@@ -23,54 +38,231 @@ type elasticRepository struct {
 }
 
 type productDocument struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	MRP         float64  `json:"mrp"`
+	Brand       string   `json:"brand"`
+	SKU         string   `json:"sku"`
+	Barcode     string   `json:"barcode"`
+	UnitSize    float64  `json:"unit_size"`
+	UnitMeasure string   `json:"unit_measure"`
+	CategoryIDs []string `json:"category_ids"`
+	ImageURLs   []string `json:"image_urls"`
+	Tags        []string `json:"tags"`
+	// Missing on products indexed before the flag existed, which count as
+	// active
+	Active *bool `json:"active,omitempty"`
 }
 
+// productMapping is applied when the index is created and merged into it on
+// startup, so new fields get their types before any product uses them.
+// Field types can't change once set: price and mrp stay float because that
+// is what dynamic mapping gave price.
+const productMapping = `{
+	"properties": {
+		"name":         {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+		"description":  {"type": "text"},
+		"price":        {"type": "float"},
+		"mrp":          {"type": "float"},
+		"brand":        {"type": "keyword"},
+		"sku":          {"type": "keyword"},
+		"barcode":      {"type": "keyword"},
+		"unit_size":    {"type": "float"},
+		"unit_measure": {"type": "keyword"},
+		"category_ids": {"type": "keyword"},
+		"image_urls":   {"type": "keyword", "index": false},
+		"tags":         {"type": "keyword"},
+		"active":       {"type": "boolean"}
+	}
+}`
+
+type categoryDocument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentID    string `json:"parent_id"`
+	Position    int32  `json:"position"`
+}
+
+const categoryMapping = `{
+	"properties": {
+		"name":        {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+		"description": {"type": "text"},
+		"parent_id":   {"type": "keyword"},
+		"position":    {"type": "integer"}
+	}
+}`
+
+// docType is the only type a typeless index accepts documents under.
+const docType = "_doc"
+
+// maxCategories bounds how many categories ListCategories loads.
+const maxCategories = 10000
+
+var ErrNotFound = errs.NotFound("product not found")
+
 func NewElasticRepository(url string) (Repository, error) {
 	client, err := elastic.NewClient(
 		elastic.SetURL(url),
 		elastic.SetSniff(false),
 	)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	if err := ensureIndex(context.Background(), client, "catalog", productMapping); err != nil {
+		return nil, err
+	}
+	if err := ensureIndex(context.Background(), client, "categories", categoryMapping); err != nil {
 		return nil, err
 	}
 	return &elasticRepository{client}, nil
 }
 
+// ensureIndex creates index with mapping, or adds any fields missing from an
+// existing index's mapping. Mappings are typeless, as Elasticsearch 8 no
+// longer has mapping types.
+func ensureIndex(ctx context.Context, client *elastic.Client, index, mapping string) error {
+	exists, err := client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		_, err = client.CreateIndex(index).BodyString(`{"mappings": ` + mapping + `}`).Do(ctx)
+		if err == nil {
+			return nil
+		}
+		// Another instance may have created it in the meantime
+		if !isErrorType(err, "resource_already_exists_exception") {
+			return fmt.Errorf("creating %s index: %w", index, err)
+		}
+	}
+	// The client only knows the typed mapping endpoint
+	if _, err := client.PerformRequest(ctx, http.MethodPut, "/"+url.PathEscape(index)+"/_mapping", nil, mapping); err != nil {
+		return fmt.Errorf("updating %s mapping: %w", index, err)
+	}
+	return nil
+}
+
+// isErrorType reports whether err is an Elasticsearch error of type typ,
+// such as "version_conflict_engine_exception".
+func isErrorType(err error, typ string) bool {
+	var e *elastic.Error
+	return errors.As(err, &e) && e.Details != nil && e.Details.Type == typ
+}
+
+func newProductDocument(p Product) productDocument {
+	return productDocument{
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		MRP:         p.MRP,
+		Brand:       p.Brand,
+		SKU:         p.SKU,
+		Barcode:     p.Barcode,
+		UnitSize:    p.UnitSize,
+		UnitMeasure: p.UnitMeasure,
+		CategoryIDs: p.CategoryIDs,
+		ImageURLs:   p.ImageURLs,
+		Tags:        p.Tags,
+		Active:      &p.Active,
+	}
+}
+
+func (d productDocument) product(id string) Product {
+	mrp := d.MRP
+	if mrp == 0 {
+		mrp = d.Price
+	}
+	return Product{
+		ID:          id,
+		Name:        d.Name,
+		Description: d.Description,
+		Price:       d.Price,
+		MRP:         mrp,
+		Brand:       d.Brand,
+		SKU:         d.SKU,
+		Barcode:     d.Barcode,
+		UnitSize:    d.UnitSize,
+		UnitMeasure: d.UnitMeasure,
+		CategoryIDs: nonNil(d.CategoryIDs),
+		ImageURLs:   nonNil(d.ImageURLs),
+		Tags:        nonNil(d.Tags),
+		Active:      d.Active == nil || *d.Active,
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// listable restricts query to products that are on sale and, when
+// categoryIDs isn't empty, linked to one of them.
+func listable(query elastic.Query, categoryIDs []string) elastic.Query {
+	q := elastic.NewBoolQuery().Must(query).MustNot(elastic.NewTermQuery("active", false))
+	if len(categoryIDs) > 0 {
+		q = q.Filter(categoryTerms(categoryIDs))
+	}
+	return q
+}
+
+func categoryTerms(categoryIDs []string) elastic.Query {
+	ids := make([]any, len(categoryIDs))
+	for i, id := range categoryIDs {
+		ids[i] = id
+	}
+	return elastic.NewTermsQuery("category_ids", ids...)
+}
+
 // Close implements Repository.
 func (e *elasticRepository) Close() {
 }
 
+// Ping reports Elasticsearch as unavailable while the cluster is red, when
+// some primary shards have no copy to serve from.
+func (e *elasticRepository) Ping(ctx context.Context) error {
+	res, err := e.client.ClusterHealth().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if res.Status == "red" {
+		return fmt.Errorf("cluster status is %s", res.Status)
+	}
+	return nil
+}
+
 // GetProductbyId implements Repository.
-func (e *elasticRepository) GetProductbyId(ctx context.Context, id string) (*Product, error) {
-	res, err := e.client.Get().Index("catalog").Type("product").Id(id).Do(ctx)
+func (e *elasticRepository) GetProductbyId(ctx context.Context, id string) (_ *Product, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "GetProductbyId")
+	defer func() { done(err) }()
+	res, err := e.client.Get().Index("catalog").Type(docType).Id(id).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "GetProductbyId", "err", err)
 		return nil, err
 	}
 	if !res.Found {
-		return nil, errors.New("entity not found")
+		return nil, ErrNotFound
 	}
 	p := productDocument{}
 	if err = json.Unmarshal(*res.Source, &p); err != nil {
 		return nil, err
 	}
-	return &Product{
-		ID:          id,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
-	}, err
+	product := p.product(id)
+	return &product, nil
 }
 
 // ListProducts implements Repository.
-func (e *elasticRepository) ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error) {
-	res, err := e.client.Search().Index("catalog").Type("product").Query(elastic.NewMatchAllQuery()).From(int(skip)).Size(int(take)).Do(ctx)
+func (e *elasticRepository) ListProducts(ctx context.Context, categoryIDs []string, skip uint64, take uint64) (_ []Product, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "ListProducts")
+	defer func() { done(err) }()
+	res, err := e.client.Search().Index("catalog").Query(listable(elastic.NewMatchAllQuery(), categoryIDs)).From(int(skip)).Size(int(take)).Do(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "ListProducts", "err", err)
 		return nil, err
 	}
 	products := []Product{}
@@ -79,75 +271,162 @@ func (e *elasticRepository) ListProducts(ctx context.Context, skip uint64, take
 		p := productDocument{}
 
 		if err = json.Unmarshal(*hit.Source, &p); err == nil {
-			products = append(products, Product{
-				ID:          hit.Id,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+			products = append(products, p.product(hit.Id))
 		}
 	}
 	return products, err
 }
 
 // ListProductsWithIds implements Repository.
-func (e *elasticRepository) ListProductsWithIds(ctx context.Context, ids []string) ([]Product, error) {
+func (e *elasticRepository) ListProductsWithIds(ctx context.Context, ids []string) (_ []Product, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "ListProductsWithIds")
+	defer func() { done(err) }()
 	items := []*elastic.MultiGetItem{}
 
 	for _, id := range ids {
-		items = append(items, elastic.NewMultiGetItem().Index("catalog").Type("product").Id(id))
+		items = append(items, elastic.NewMultiGetItem().Index("catalog").Type(docType).Id(id))
 	}
 	res, err := e.client.MultiGet().Add(items...).Do(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "ListProductsWithIds", "err", err)
 		return nil, err
 	}
 	products := []Product{}
 
 	for _, doc := range res.Docs {
+		if !doc.Found {
+			continue
+		}
 		p := productDocument{}
 
 		if err = json.Unmarshal(*doc.Source, &p); err == nil {
-			products = append(products, Product{
-				ID:          doc.Id,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+			products = append(products, p.product(doc.Id))
 		}
 	}
 	return products, err
 }
 
 // PutProduct implements Repository.
-func (e *elasticRepository) PutProduct(ctx context.Context, p Product) error {
-	_, err := e.client.Index().Index("catalog").Type("product").Id(p.ID).BodyJson(productDocument{
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
-	}).Do(ctx)
+func (e *elasticRepository) PutProduct(ctx context.Context, p Product) (err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "PutProduct")
+	defer func() { done(err) }()
+	_, err = e.client.Index().Index("catalog").Type(docType).Id(p.ID).BodyJson(newProductDocument(p)).Do(ctx)
 	return err
 }
 
 // SearchProduct implements Repository.
-func (e *elasticRepository) SearchProduct(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error) {
-	res, err := e.client.Search().Index("catalog").Type("product").Query(elastic.NewMultiMatchQuery(query, "name", "description")).From(int(skip)).Size(int(take)).Do(ctx)
+func (e *elasticRepository) SearchProduct(ctx context.Context, query string, categoryIDs []string, skip uint64, take uint64) (_ []Product, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "SearchProduct")
+	defer func() { done(err) }()
+	res, err := e.client.Search().Index("catalog").Query(listable(elastic.NewMultiMatchQuery(query, "name^3", "brand^2", "tags^2", "description"), categoryIDs)).From(int(skip)).Size(int(take)).Do(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "SearchProduct", "err", err)
 		return nil, err
 	}
 	products := []Product{}
 	for _, hit := range res.Hits.Hits {
 		p := productDocument{}
 		if err = json.Unmarshal(*hit.Source, &p); err == nil {
-			products = append(products, Product{
-				ID:          hit.Id,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+			products = append(products, p.product(hit.Id))
 		}
 	}
 
 	return products, err
 }
+
+// CountProducts implements Repository.
+func (e *elasticRepository) CountProducts(ctx context.Context, categoryIDs []string) (_ int64, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "CountProducts")
+	defer func() { done(err) }()
+	n, err := e.client.Count("catalog").Query(categoryTerms(categoryIDs)).Do(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "CountProducts", "err", err)
+		return 0, err
+	}
+	return n, nil
+}
+
+// PutCategory implements Repository. It only overwrites the revision c was
+// read at, failing with ErrCategoryConflict when the category changed since.
+// The write is visible to searches when it returns, so the tree reads back
+// consistently right after a change.
+func (e *elasticRepository) PutCategory(ctx context.Context, c Category) (err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "PutCategory")
+	defer func() { done(err) }()
+	// The client predates if_seq_no and if_primary_term
+	params := url.Values{"refresh": {"wait_for"}}
+	if c.seqNo == 0 && c.primaryTerm == 0 {
+		params.Set("op_type", "create")
+	} else {
+		params.Set("if_seq_no", strconv.FormatInt(c.seqNo, 10))
+		params.Set("if_primary_term", strconv.FormatInt(c.primaryTerm, 10))
+	}
+	_, err = e.client.PerformRequest(ctx, http.MethodPut, "/categories/"+docType+"/"+url.PathEscape(c.ID), params, categoryDocument{
+		Name:        c.Name,
+		Description: c.Description,
+		ParentID:    c.ParentID,
+		Position:    c.Position,
+	})
+	if isErrorType(err, "version_conflict_engine_exception") {
+		return ErrCategoryConflict
+	}
+	return err
+}
+
+// categorySearchResult is the part of a search response ListCategories
+// reads, including the revision of each category.
+type categorySearchResult struct {
+	Hits struct {
+		Hits []struct {
+			ID          string           `json:"_id"`
+			SeqNo       int64            `json:"_seq_no"`
+			PrimaryTerm int64            `json:"_primary_term"`
+			Source      categoryDocument `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// ListCategories implements Repository.
+func (e *elasticRepository) ListCategories(ctx context.Context) (_ []Category, err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "ListCategories")
+	defer func() { done(err) }()
+	params := url.Values{
+		"seq_no_primary_term": {"true"},
+		"size":                {strconv.Itoa(maxCategories)},
+	}
+	res, err := e.client.PerformRequest(ctx, http.MethodPost, "/categories/_search", params, map[string]any{
+		"query": map[string]any{"match_all": map[string]any{}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Elasticsearch query failed", "operation", "ListCategories", "err", err)
+		return nil, err
+	}
+	result := categorySearchResult{}
+	if err := json.Unmarshal(res.Body, &result); err != nil {
+		return nil, err
+	}
+	categories := []Category{}
+	for _, hit := range result.Hits.Hits {
+		categories = append(categories, Category{
+			ID:          hit.ID,
+			Name:        hit.Source.Name,
+			Description: hit.Source.Description,
+			ParentID:    hit.Source.ParentID,
+			Position:    hit.Source.Position,
+			seqNo:       hit.SeqNo,
+			primaryTerm: hit.PrimaryTerm,
+		})
+	}
+	return categories, nil
+}
+
+// DeleteCategory implements Repository.
+func (e *elasticRepository) DeleteCategory(ctx context.Context, id string) (err error) {
+	ctx, done := tracing.Query(ctx, "elasticsearch", "DeleteCategory")
+	defer func() { done(err) }()
+	_, err = e.client.Delete().Index("categories").Type(docType).Id(id).Refresh("wait_for").Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrCategoryNotFound
+	}
+	return err
+}
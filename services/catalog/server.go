@@ -5,9 +5,17 @@ package catalog
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"time"
 
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/catalog/pb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -18,47 +26,74 @@ type grpcServer struct {
 	service Service
 }
 
-func ListenGrpcServer(s Service, port int) error {
+// roleRules lists the RPCs that need an access token with one of the roles,
+// or the service key for the stock reservations made by the order service.
+var roleRules = map[string][]string{
+	pb.CatalogService_PostProduct_FullMethodName:    {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_UpdateProduct_FullMethodName:  {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_CreateCategory_FullMethodName: {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_UpdateCategory_FullMethodName: {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_MoveCategory_FullMethodName:   {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_DeleteCategory_FullMethodName: {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_AdjustStock_FullMethodName:    {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_ListLowStock_FullMethodName:   {auth.RoleAdmin, auth.RoleCatalogManager},
+	pb.CatalogService_ReserveStock_FullMethodName:   {auth.RoleService},
+	pb.CatalogService_ReleaseStock_FullMethodName:   {auth.RoleService},
+}
+
+// ListenGrpcServer serves the catalog service until ctx is done, checking
+// role-protected RPCs with verify, or serviceKey for other services, and
+// reporting health from checks. On
+// shutdown it keeps serving for drainDelay while reporting NOT_SERVING, then
+// gives in-flight RPCs up to shutdownTimeout to finish.
+func ListenGrpcServer(ctx context.Context, s Service, verify auth.Verifier, serviceKey string, checks health.Checks, port int, drainDelay, shutdownTimeout time.Duration) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			metrics.UnaryServerInterceptor(),
+			logging.UnaryServerInterceptor(),
+			errs.UnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(verify, serviceKey, roleRules),
+		),
+	)
 	pb.RegisterCatalogServiceServer(server, &grpcServer{UnimplementedCatalogServiceServer: pb.UnimplementedCatalogServiceServer{}, service: s})
+	hs := health.Register(ctx, server, pb.CatalogService_ServiceDesc.ServiceName, checks)
 	reflection.Register(server)
-	return server.Serve(lis)
+	return lifecycle.ServeGRPC(ctx, server, hs, lis, drainDelay, shutdownTimeout)
 }
 
 func (server *grpcServer) PostProduct(ctx context.Context, r *pb.PostProductRequest) (*pb.PostProductResponse, error) {
-	p, err := server.service.PostProduct(ctx, r.Name, r.Description, r.Price)
+	p, err := server.service.PostProduct(ctx, productFromProto(r.Product))
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Error posting product", "err", err)
 		return nil, err
 	}
 
 	return &pb.PostProductResponse{
-		Product: &pb.Product{
-			Id:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		},
+		Product: productToProto(p),
 	}, nil
 }
 
+func (server *grpcServer) UpdateProduct(ctx context.Context, r *pb.UpdateProductRequest) (*pb.UpdateProductResponse, error) {
+	p, err := server.service.UpdateProduct(ctx, r.Id, r.CategoryIds, r.Active)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpdateProductResponse{Product: productToProto(p)}, nil
+}
+
 func (server *grpcServer) GetProduct(ctx context.Context, r *pb.GetProductRequest) (*pb.GetProductResponse, error) {
 	p, err := server.service.GetProduct(ctx, r.Id)
 	if err != nil {
 		return nil, err
 	}
 	return &pb.GetProductResponse{
-		Product: &pb.Product{
-			Id:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		},
+		Product: productToProto(p),
 	}, nil
 }
 
@@ -67,28 +102,241 @@ func (server *grpcServer) GetProducts(ctx context.Context, r *pb.GetProductsRequ
 	var err error
 
 	if r.Query != "" {
-		res, err = server.service.SearchProducts(ctx, r.Query, r.Skip, r.Take)
+		res, err = server.service.SearchProducts(ctx, r.Query, r.CategoryId, r.Skip, r.Take)
 	} else if len(r.Ids) != 0 {
 		res, err = server.service.GetProductsbyIds(ctx, r.Ids)
 	} else {
-		res, err = server.service.GetProducts(ctx, r.Skip, r.Take)
+		res, err = server.service.GetProducts(ctx, r.CategoryId, r.Skip, r.Take)
 	}
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Error getting products", "err", err)
 		return nil, err
 	}
 
 	products := []*pb.Product{}
 	for _, p := range res {
-		products = append(products, &pb.Product{
-			Id:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		})
+		products = append(products, productToProto(&p))
 	}
 	return &pb.GetProductsResponse{
 		Products: products,
 	}, nil
 }
+
+func (server *grpcServer) CreateCategory(ctx context.Context, r *pb.CategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := server.service.CreateCategory(ctx, categoryFromProto(r.Category))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (server *grpcServer) UpdateCategory(ctx context.Context, r *pb.CategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := server.service.UpdateCategory(ctx, categoryFromProto(r.Category))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (server *grpcServer) MoveCategory(ctx context.Context, r *pb.MoveCategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := server.service.MoveCategory(ctx, r.Id, r.ParentId, r.Position)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (server *grpcServer) DeleteCategory(ctx context.Context, r *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	if err := server.service.DeleteCategory(ctx, r.Id); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteCategoryResponse{}, nil
+}
+
+func (server *grpcServer) GetCategoryTree(ctx context.Context, r *pb.GetCategoryTreeRequest) (*pb.GetCategoryTreeResponse, error) {
+	nodes, err := server.service.GetCategoryTree(ctx, r.RootId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting category tree", "err", err)
+		return nil, err
+	}
+	return &pb.GetCategoryTreeResponse{Categories: categoryNodesToProto(nodes)}, nil
+}
+
+func (server *grpcServer) AdjustStock(ctx context.Context, r *pb.AdjustStockRequest) (*pb.AdjustStockResponse, error) {
+	stock, err := server.service.AdjustStock(ctx, r.StoreId, stockDeltasFromProto(r.Items), r.Reason, r.Note, r.Reference, r.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AdjustStockResponse{Stock: stockToProto(stock)}, nil
+}
+
+func (server *grpcServer) ReserveStock(ctx context.Context, r *pb.StockReservationRequest) (*pb.StockReservationResponse, error) {
+	stock, err := server.service.ReserveStock(ctx, r.StoreId, r.OrderId, stockDeltasFromProto(r.Items))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StockReservationResponse{Stock: stockToProto(stock)}, nil
+}
+
+func (server *grpcServer) ReleaseStock(ctx context.Context, r *pb.ReleaseStockRequest) (*pb.StockReservationResponse, error) {
+	stock, err := server.service.ReleaseStock(ctx, r.StoreId, r.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StockReservationResponse{Stock: stockToProto(stock)}, nil
+}
+
+func (server *grpcServer) GetStock(ctx context.Context, r *pb.GetStockRequest) (*pb.GetStockResponse, error) {
+	stock, err := server.service.GetStock(ctx, r.StoreId, r.ProductIds)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting stock", "err", err)
+		return nil, err
+	}
+	return &pb.GetStockResponse{Stock: stockToProto(stock)}, nil
+}
+
+func (server *grpcServer) ListLowStock(ctx context.Context, r *pb.ListLowStockRequest) (*pb.ListLowStockResponse, error) {
+	stock, err := server.service.ListLowStock(ctx, r.StoreId, r.Threshold, r.Skip, r.Take)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing low stock", "err", err)
+		return nil, err
+	}
+	return &pb.ListLowStockResponse{Stock: stockToProto(stock)}, nil
+}
+
+func stockToProto(stock []Stock) []*pb.Stock {
+	out := []*pb.Stock{}
+	for _, s := range stock {
+		var updatedAt int64
+		if !s.UpdatedAt.IsZero() {
+			updatedAt = s.UpdatedAt.Unix()
+		}
+		out = append(out, &pb.Stock{
+			ProductId: s.ProductID,
+			StoreId:   s.StoreID,
+			Quantity:  s.Quantity,
+			UpdatedAt: updatedAt,
+		})
+	}
+	return out
+}
+
+func stockFromProto(stock []*pb.Stock) []Stock {
+	out := []Stock{}
+	for _, s := range stock {
+		var updatedAt time.Time
+		if s.UpdatedAt != 0 {
+			updatedAt = time.Unix(s.UpdatedAt, 0)
+		}
+		out = append(out, Stock{
+			ProductID: s.ProductId,
+			StoreID:   s.StoreId,
+			Quantity:  s.Quantity,
+			UpdatedAt: updatedAt,
+		})
+	}
+	return out
+}
+
+func stockDeltasToProto(items []StockDelta) []*pb.StockDelta {
+	out := []*pb.StockDelta{}
+	for _, item := range items {
+		out = append(out, &pb.StockDelta{ProductId: item.ProductID, Delta: item.Delta})
+	}
+	return out
+}
+
+func stockDeltasFromProto(items []*pb.StockDelta) []StockDelta {
+	out := []StockDelta{}
+	for _, item := range items {
+		out = append(out, StockDelta{ProductID: item.ProductId, Delta: item.Delta})
+	}
+	return out
+}
+
+func categoryToProto(c *Category) *pb.Category {
+	return &pb.Category{
+		Id:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		ParentId:    c.ParentID,
+		Position:    c.Position,
+	}
+}
+
+func categoryFromProto(c *pb.Category) Category {
+	if c == nil {
+		return Category{}
+	}
+	return Category{
+		ID:          c.Id,
+		Name:        c.Name,
+		Description: c.Description,
+		ParentID:    c.ParentId,
+		Position:    c.Position,
+	}
+}
+
+func categoryNodesToProto(nodes []*CategoryNode) []*pb.CategoryNode {
+	out := []*pb.CategoryNode{}
+	for _, n := range nodes {
+		out = append(out, &pb.CategoryNode{
+			Category: categoryToProto(&n.Category),
+			Children: categoryNodesToProto(n.Children),
+		})
+	}
+	return out
+}
+
+func categoryNodesFromProto(nodes []*pb.CategoryNode) []*CategoryNode {
+	out := []*CategoryNode{}
+	for _, n := range nodes {
+		out = append(out, &CategoryNode{
+			Category: categoryFromProto(n.Category),
+			Children: categoryNodesFromProto(n.Children),
+		})
+	}
+	return out
+}
+
+func productToProto(p *Product) *pb.Product {
+	return &pb.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Mrp:         p.MRP,
+		Brand:       p.Brand,
+		Sku:         p.SKU,
+		Barcode:     p.Barcode,
+		UnitSize:    p.UnitSize,
+		UnitMeasure: p.UnitMeasure,
+		CategoryIds: p.CategoryIDs,
+		ImageUrls:   p.ImageURLs,
+		Tags:        p.Tags,
+		Active:      p.Active,
+	}
+}
+
+func productFromProto(p *pb.Product) Product {
+	if p == nil {
+		return Product{}
+	}
+	return Product{
+		ID:          p.Id,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		MRP:         p.Mrp,
+		Brand:       p.Brand,
+		SKU:         p.Sku,
+		Barcode:     p.Barcode,
+		UnitSize:    p.UnitSize,
+		UnitMeasure: p.UnitMeasure,
+		CategoryIDs: nonNil(p.CategoryIds),
+		ImageURLs:   nonNil(p.ImageUrls),
+		Tags:        nonNil(p.Tags),
+		Active:      p.Active,
+	}
+}
@@ -2,46 +2,168 @@ package catalog
 
 import (
 	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/errs"
 )
 
 type Service interface {
-	PostProduct(ctx context.Context, name string, description string, price float64) (*Product, error)
+	PostProduct(ctx context.Context, p Product) (*Product, error)
+	// UpdateProduct replaces the product's categories and sets whether it
+	// is on sale
+	UpdateProduct(ctx context.Context, id string, categoryIDs []string, active bool) (*Product, error)
 	GetProduct(ctx context.Context, id string) (*Product, error)
-	GetProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error)
+	// GetProducts and SearchProducts only return products in categoryID or
+	// its subcategories when it is set
+	GetProducts(ctx context.Context, categoryID string, skip uint64, take uint64) ([]Product, error)
 	GetProductsbyIds(ctx context.Context, ids []string) ([]Product, error)
-	SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error)
+	SearchProducts(ctx context.Context, query string, categoryID string, skip uint64, take uint64) ([]Product, error)
+	CreateCategory(ctx context.Context, c Category) (*Category, error)
+	UpdateCategory(ctx context.Context, c Category) (*Category, error)
+	MoveCategory(ctx context.Context, id, parentID string, position int32) (*Category, error)
+	DeleteCategory(ctx context.Context, id string) error
+	GetCategoryTree(ctx context.Context, rootID string) ([]*CategoryNode, error)
+	AdjustStock(ctx context.Context, storeID string, items []StockDelta, reason, note, reference, idempotencyKey string) ([]Stock, error)
+	ReserveStock(ctx context.Context, storeID, orderID string, items []StockDelta) ([]Stock, error)
+	ReleaseStock(ctx context.Context, storeID, orderID string) ([]Stock, error)
+	GetStock(ctx context.Context, storeID string, productIDs []string) ([]Stock, error)
+	ListLowStock(ctx context.Context, storeID string, threshold int32, skip uint64, take uint64) ([]Stock, error)
 }
 
+// Product is a sellable item. Price is the selling price, which may be
+// below the MRP printed on the pack. UnitSize and UnitMeasure describe the
+// pack, e.g. 500 g.
 type Product struct {
-	ID          string  `json:"Id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID          string   `json:"Id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	MRP         float64  `json:"mrp"`
+	Brand       string   `json:"brand"`
+	SKU         string   `json:"sku"`
+	Barcode     string   `json:"barcode"`
+	UnitSize    float64  `json:"unitSize"`
+	UnitMeasure string   `json:"unitMeasure"`
+	CategoryIDs []string `json:"categoryIds"`
+	ImageURLs   []string `json:"imageUrls"`
+	Tags        []string `json:"tags"`
+	Active      bool     `json:"active"`
+}
+
+// Units a pack size can be measured in.
+var unitMeasures = map[string]bool{"g": true, "kg": true, "ml": true, "l": true, "pc": true}
+
+var barcodeRegex = regexp.MustCompile(`^[0-9]{8,14}$`)
+
+// normalize trims and lowercases what clients shouldn't have to get exactly
+// right, defaults the MRP to the selling price and rejects the rest.
+func (p *Product) normalize() error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Brand = strings.TrimSpace(p.Brand)
+	p.SKU = strings.ToUpper(strings.TrimSpace(p.SKU))
+	p.Barcode = strings.TrimSpace(p.Barcode)
+	p.UnitMeasure = strings.ToLower(strings.TrimSpace(p.UnitMeasure))
+	if p.Name == "" {
+		return errs.InvalidArgument("name", "name is required")
+	}
+	if p.Price <= 0 {
+		return errs.InvalidArgument("price", "price must be positive")
+	}
+	if p.MRP == 0 {
+		p.MRP = p.Price
+	}
+	if p.MRP < p.Price {
+		return errs.InvalidArgument("mrp", "price must not exceed the MRP")
+	}
+	if p.Barcode != "" && !barcodeRegex.MatchString(p.Barcode) {
+		return errs.InvalidArgument("barcode", "barcode must be an EAN/UPC of 8 to 14 digits")
+	}
+	if p.UnitMeasure != "" && !unitMeasures[p.UnitMeasure] {
+		return errs.InvalidArgument("unitMeasure", "unit measure must be one of g, kg, ml, l or pc")
+	}
+	if (p.UnitMeasure == "") != (p.UnitSize == 0) || p.UnitSize < 0 {
+		return errs.InvalidArgument("unitSize", "unit size needs a positive size and a unit measure")
+	}
+	for _, raw := range p.ImageURLs {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errs.InvalidArgument("imageUrls", "image URLs must be absolute http(s) URLs")
+		}
+	}
+	p.CategoryIDs = dedupe(p.CategoryIDs, strings.TrimSpace)
+	p.Tags = dedupe(p.Tags, func(tag string) string {
+		return strings.ToLower(strings.TrimSpace(tag))
+	})
+	return nil
+}
+
+// dedupe returns the non-empty values after clean, keeping the first of
+// each.
+func dedupe(values []string, clean func(string) string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range values {
+		v = clean(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
 }
 
 type catalogService struct {
 	repository Repository
+	inventory  InventoryRepository
+	// Stock at or below this level is low unless a caller asks otherwise
+	lowStockThreshold int32
+
+	treeMu     sync.Mutex
+	tree       categoryTree
+	treeLoaded time.Time
 }
 
-func NewService(r Repository) Service {
-	return &catalogService{r}
+func NewService(r Repository, inventory InventoryRepository, lowStockThreshold int32) Service {
+	return &catalogService{repository: r, inventory: inventory, lowStockThreshold: lowStockThreshold}
 }
 
-func (cs *catalogService) PostProduct(ctx context.Context, name string, description string, price float64) (*Product, error) {
-	product := &Product{
-		ID:          ksuid.New().String(),
-		Name:        name,
-		Description: description,
-		Price:       price,
+func (cs *catalogService) PostProduct(ctx context.Context, p Product) (*Product, error) {
+	if err := p.normalize(); err != nil {
+		return nil, err
+	}
+	if err := cs.checkCategories(ctx, p.CategoryIDs); err != nil {
+		return nil, err
+	}
+	p.ID = ksuid.New().String()
+
+	err := cs.repository.PutProduct(ctx, p)
+	if err != nil {
+		return nil, err
 	}
+	return &p, nil
+}
 
-	err := cs.repository.PutProduct(ctx, *product)
+func (cs *catalogService) UpdateProduct(ctx context.Context, id string, categoryIDs []string, active bool) (*Product, error) {
+	p, err := cs.repository.GetProductbyId(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return product, nil
+	p.CategoryIDs = dedupe(categoryIDs, strings.TrimSpace)
+	if err := cs.checkCategories(ctx, p.CategoryIDs); err != nil {
+		return nil, err
+	}
+	p.Active = active
+
+	if err := cs.repository.PutProduct(ctx, *p); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func (cs *catalogService) GetProduct(ctx context.Context, id string) (*Product, error) {
@@ -53,11 +175,15 @@ func (cs *catalogService) GetProduct(ctx context.Context, id string) (*Product,
 	return product, nil
 }
 
-func (cs *catalogService) GetProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error) {
+func (cs *catalogService) GetProducts(ctx context.Context, categoryID string, skip uint64, take uint64) ([]Product, error) {
 	if take > 100 || (skip == 0 && take == 0) {
 		take = 100
 	}
-	products, err := cs.repository.ListProducts(ctx, skip, take)
+	categoryIDs, err := cs.categoryFilter(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	products, err := cs.repository.ListProducts(ctx, categoryIDs, skip, take)
 	if err != nil {
 		return nil, err
 	}
@@ -73,12 +199,16 @@ func (cs *catalogService) GetProductsbyIds(ctx context.Context, ids []string) ([
 }
 
 func (cs *catalogService) SearchProducts(ctx context.Context,
-	query string, skip uint64, take uint64,
+	query string, categoryID string, skip uint64, take uint64,
 ) ([]Product, error) {
 	if take > 100 || (skip == 0 && take == 0) {
 		take = 100
 	}
-	products, err := cs.repository.SearchProduct(ctx, query, skip, take)
+	categoryIDs, err := cs.categoryFilter(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	products, err := cs.repository.SearchProduct(ctx, query, categoryIDs, skip, take)
 	if err != nil {
 		return nil, err
 	}
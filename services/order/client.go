@@ -2,9 +2,14 @@ package order
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/order/pb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -16,7 +21,11 @@ type Client struct {
 }
 
 func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(url,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(), logging.UnaryClientInterceptor(), auth.UnaryClientInterceptor()),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -28,10 +37,18 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Ping checks the order service through its gRPC health service.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.GRPC(c.conn, pb.OrderService_ServiceDesc.ServiceName)(ctx)
+}
+
 func (c *Client) PostOrder(
 	ctx context.Context,
 	accountID string,
 	products []OrderedProduct,
+	paymentMethod string,
+	storeID string,
+	idempotencyKey string,
 ) (*Order, error) {
 	protoProducts := []*pb.PostOrderRequest_OrderProduct{}
 	for _, p := range products {
@@ -43,8 +60,11 @@ func (c *Client) PostOrder(
 	r, err := c.service.PostOrder(
 		ctx,
 		&pb.PostOrderRequest{
-			AccountId: accountID,
-			Products:  protoProducts,
+			AccountId:      accountID,
+			Products:       protoProducts,
+			PaymentMethod:  paymentMethod,
+			StoreId:        storeID,
+			IdempotencyKey: idempotencyKey,
 		},
 	)
 	if err != nil {
@@ -57,11 +77,13 @@ func (c *Client) PostOrder(
 	newOrderCreatedAt.UnmarshalBinary(newOrder.CreatedAt)
 
 	return &Order{
-		ID:         newOrder.Id,
-		CreatedAt:  newOrderCreatedAt,
-		TotalPrice: newOrder.TotalPrice,
-		AccountId:  newOrder.AccountId,
-		Products:   products,
+		ID:            newOrder.Id,
+		CreatedAt:     newOrderCreatedAt,
+		TotalPrice:    newOrder.TotalPrice,
+		AccountId:     newOrder.AccountId,
+		PaymentMethod: newOrder.PaymentMethod,
+		StoreID:       newOrder.StoreId,
+		Products:      products,
 	}, nil
 }
 
@@ -70,16 +92,18 @@ func (c *Client) GetOrdersForAccount(ctx context.Context, accountID string) ([]O
 		AccountId: accountID,
 	})
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Error getting orders for account", "account_id", accountID, "err", err)
 		return nil, err
 	}
 
 	orders := []Order{}
 	for _, orderProto := range r.Orders {
 		newOrder := Order{
-			ID:         orderProto.Id,
-			TotalPrice: orderProto.TotalPrice,
-			AccountId:  orderProto.AccountId,
+			ID:            orderProto.Id,
+			TotalPrice:    orderProto.TotalPrice,
+			AccountId:     orderProto.AccountId,
+			PaymentMethod: orderProto.PaymentMethod,
+			StoreID:       orderProto.StoreId,
 		}
 		newOrder.CreatedAt = time.Time{}
 		newOrder.CreatedAt.UnmarshalBinary(orderProto.CreatedAt)
@@ -1,37 +1,138 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"sync"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/migrate"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+	"github.com/theshubhamy/microGo/services/account"
+	"github.com/theshubhamy/microGo/services/catalog"
 	"github.com/theshubhamy/microGo/services/order"
 	"github.com/tinrab/retry"
 )
 
-type Config struct {
-	DatabaseURL string `envconfig:"DATABASE_URL"`
-	AccountURL  string `envconfig:"ACCOUNT_SERVICE_URL"`
-	CatalogURL  string `envconfig:"CATALOG_SERVICE_URL"`
-}
-
 func main() {
-	var config Config
-	err := envconfig.Process("", &config)
+	config, err := order.LoadConfig(os.Getenv("CONFIG_FILE"))
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to load config", "err", err)
+	}
+	logging.Setup("order", config.Logging)
+	flushTraces, err := tracing.Setup(context.Background(), "order", config.Options)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "err", err)
+	}
+	defer flushTraces()
+
+	var migrator *migrate.Migrator
+	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
+		migrator, err = order.NewMigrator(config.DatabaseURL)
+		if err != nil {
+			slog.Error("Failed to connect to Postgres", "err", err)
+		}
+		return
+	})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate.Command(context.Background(), migrator, os.Args[2:], os.Stdout)
+		migrator.Close()
+		if err != nil {
+			logging.Fatal("Migration failed", "err", err)
+		}
+		return
+	}
+	applied, err := migrator.Up(context.Background())
+	migrator.Close()
+	if err != nil {
+		logging.Fatal("Failed to migrate database", "err", err)
+	}
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
 	}
+
 	var r order.Repository
 
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
 		r, err = order.NewPostgresRepository(config.DatabaseURL)
 		if err != nil {
-			log.Println(err)
+			slog.Error("Failed to connect to Postgres", "err", err)
 		}
 		return
 	})
 	defer r.Close()
-	log.Println("Server running at 8080 ...")
-	s := order.NewService(r)
-	log.Fatal(order.ListenGrpcServer(s, config.AccountURL, config.CatalogURL, 8080))
+
+	accountClient, err := account.NewClient(config.AccountURL, auth.ServiceCredentials(config.ServiceKey))
+	if err != nil {
+		logging.Fatal("Failed to create account client", "err", err)
+	}
+	defer accountClient.Close()
+	catalogClient, err := catalog.NewClient(config.CatalogURL, auth.ServiceCredentials(config.ServiceKey))
+	if err != nil {
+		logging.Fatal("Failed to create catalog client", "err", err)
+	}
+	defer catalogClient.Close()
+
+	// Needed to verify the access token of the account placing an order
+	verifier := account.NewJWKSVerifier(config.JWKSURL, config.JWKSRefresh)
+
+	slog.Info("Server running", "port", config.Port)
+	s := order.NewService(r, accountClient, catalogClient, config.DefaultStoreID)
+	policy := account.VerificationPolicy{
+		RequireEmail: config.RequireVerifiedEmail,
+		RequirePhone: config.RequireVerifiedPhone,
+	}
+	checks := health.Checks{
+		"postgres": r.Ping,
+		"account":  accountClient.Ping,
+		"catalog":  catalogClient.Ping,
+	}
+
+	// Everything below stops on SIGTERM; the deferred closes above run once
+	// the background work has finished
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Complete orders whose checkout stopped half way and was never retried
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(config.PendingOrderInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := s.ResumePendingOrders(ctx, config.PendingOrderTimeout)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error resuming pending orders", "err", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Resumed pending orders", "count", n)
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := metrics.Serve(ctx, config.MetricsPort, config.ShutdownTimeout); err != nil {
+			logging.Fatal("Metrics server failed", "err", err)
+		}
+	}()
+	if err := order.ListenGrpcServer(ctx, s, verifier.VerifyAccessToken, accountClient, catalogClient, policy, checks, config.Port, config.DrainDelay, config.ShutdownTimeout); err != nil {
+		logging.Fatal("Server failed", "err", err)
+	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,96 @@
+package order
+
+import (
+	"errors"
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/config"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/tracing"
+)
+
+// Config is the order service configuration.
+type Config struct {
+	Port        int    `envconfig:"PORT" yaml:"port"`
+	MetricsPort int    `envconfig:"METRICS_PORT" yaml:"metrics_port"`
+	DatabaseURL string `envconfig:"DATABASE_URL" yaml:"database_url"`
+	AccountURL  string `envconfig:"ACCOUNT_SERVICE_URL" yaml:"account_service_url"`
+	CatalogURL  string `envconfig:"CATALOG_SERVICE_URL" yaml:"catalog_service_url"`
+	// Identifies this service to the account service for wallet debits and
+	// to the catalog service for stock reservations
+	ServiceKey string `envconfig:"SERVICE_KEY" yaml:"service_key"`
+	// Access tokens are verified against the account service's public keys
+	JWKSURL     string        `envconfig:"JWKS_URL" yaml:"jwks_url"`
+	JWKSRefresh time.Duration `envconfig:"JWKS_REFRESH" yaml:"jwks_refresh"`
+	// Dark store orders are fulfilled from when the client doesn't pick one.
+	// Without it every order must name its store.
+	DefaultStoreID string `envconfig:"DEFAULT_STORE_ID" yaml:"default_store_id"`
+	// Only accounts with verified contact details may place orders
+	RequireVerifiedEmail bool `envconfig:"REQUIRE_VERIFIED_EMAIL" yaml:"require_verified_email"`
+	RequireVerifiedPhone bool `envconfig:"REQUIRE_VERIFIED_PHONE" yaml:"require_verified_phone"`
+	// Orders still pending after the timeout, for example because the client
+	// never retried a checkout that timed out, are completed in the background
+	PendingOrderTimeout  time.Duration `envconfig:"PENDING_ORDER_TIMEOUT" yaml:"pending_order_timeout"`
+	PendingOrderInterval time.Duration `envconfig:"PENDING_ORDER_INTERVAL" yaml:"pending_order_interval"`
+	// How long to keep serving after SIGTERM while reporting not ready, so
+	// load balancers stop sending new requests
+	DrainDelay time.Duration `envconfig:"DRAIN_DELAY" yaml:"drain_delay"`
+	// How long in-flight requests may run on after SIGTERM
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	tracing.Options `yaml:",inline"`
+	Logging         logging.Options `yaml:",inline"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port:                 8080,
+		MetricsPort:          9090,
+		JWKSURL:              "http://account:8081/.well-known/jwks.json",
+		JWKSRefresh:          10 * time.Minute,
+		PendingOrderTimeout:  5 * time.Minute,
+		PendingOrderInterval: time.Minute,
+		DrainDelay:           5 * time.Second,
+		ShutdownTimeout:      15 * time.Second,
+	}
+}
+
+// LoadConfig overlays the YAML file at path, if any, and the environment on
+// DefaultConfig. Orders can't be placed without the account and catalog
+// addresses and the service key, so those must be set.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	err := config.Load(path, &cfg)
+	return cfg, err
+}
+
+func (c *Config) Validate() error {
+	err := config.Required(map[string]string{
+		"DATABASE_URL":        c.DatabaseURL,
+		"ACCOUNT_SERVICE_URL": c.AccountURL,
+		"CATALOG_SERVICE_URL": c.CatalogURL,
+		"SERVICE_KEY":         c.ServiceKey,
+		"JWKS_URL":            c.JWKSURL,
+	})
+	if err != nil {
+		return err
+	}
+	if c.JWKSRefresh <= 0 {
+		return errors.New("JWKS_REFRESH must be positive")
+	}
+	if c.PendingOrderTimeout <= 0 {
+		return errors.New("PENDING_ORDER_TIMEOUT must be positive")
+	}
+	if c.PendingOrderInterval <= 0 {
+		return errors.New("PENDING_ORDER_INTERVAL must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.DrainDelay < 0 {
+		return errors.New("DRAIN_DELAY must not be negative")
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	return c.Options.Validate()
+}
@@ -0,0 +1,29 @@
+package order
+
+import (
+	"database/sql"
+	"embed"
+
+	"github.com/theshubhamy/microGo/pkg/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// NewMigrator connects to the order database to apply the schema migrations
+// embedded in the binary.
+func NewMigrator(url string) (*migrate.Migrator, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	m, err := migrate.New(db, "order", migrations, "migrations")
+	if err != nil {
+		db.Close()
+	}
+	return m, err
+}
@@ -2,7 +2,7 @@ CREATE TABLE IF NOT EXISTS orders (
   id CHAR(27) PRIMARY KEY,
   created_at TIMESTAMP WITH TIME ZONE NOT NULL,
   account_id VARCHAR(24) NOT NULL,
-  total_price MONEY NOT NULL,
+  total_price MONEY NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS order_products(
@@ -0,0 +1,2 @@
+-- Orders placed before wallet checkout were cash on delivery
+ALTER TABLE orders ADD COLUMN payment_method VARCHAR(16) NOT NULL DEFAULT 'cod';
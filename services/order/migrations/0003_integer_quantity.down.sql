@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_orders_account;
+-- account_id stays CHAR(27), account ids never fit the old VARCHAR(24)
+
+ALTER TABLE order_products
+  DROP CONSTRAINT IF EXISTS order_products_quantity_positive,
+  ALTER COLUMN quantity TYPE CHAR(27) USING quantity::text;
@@ -0,0 +1,7 @@
+-- Quantities were stored as text and order ids are 27-character KSUIDs
+ALTER TABLE order_products
+  ALTER COLUMN quantity TYPE INTEGER USING trim(quantity)::integer,
+  ADD CONSTRAINT order_products_quantity_positive CHECK (quantity > 0);
+
+ALTER TABLE orders ALTER COLUMN account_id TYPE CHAR(27);
+CREATE INDEX IF NOT EXISTS idx_orders_account ON orders (account_id);
@@ -0,0 +1,3 @@
+-- The dark store an order is picked from; empty for orders placed before
+-- stock was tracked
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS store_id VARCHAR(64) NOT NULL DEFAULT '';
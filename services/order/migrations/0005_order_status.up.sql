@@ -0,0 +1,5 @@
+-- Checkout records an order as pending before reserving stock and charging
+-- the wallet, so a retry can finish it. Existing orders were all placed.
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'placed'
+  CHECK (status IN ('pending', 'placed', 'failed'));
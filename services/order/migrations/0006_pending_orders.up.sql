@@ -0,0 +1,2 @@
+-- Orders left pending are looked up by age to be completed in the background
+CREATE INDEX IF NOT EXISTS orders_pending_created_at_idx ON orders (created_at) WHERE status = 'pending';
@@ -28,6 +28,8 @@ type Order struct {
 	AccountId     string                 `protobuf:"bytes,3,opt,name=accountId,proto3" json:"accountId,omitempty"`
 	TotalPrice    float64                `protobuf:"fixed64,4,opt,name=totalPrice,proto3" json:"totalPrice,omitempty"`
 	Products      []*Order_OrderProduct  `protobuf:"bytes,5,rep,name=products,proto3" json:"products,omitempty"`
+	PaymentMethod string                 `protobuf:"bytes,6,opt,name=paymentMethod,proto3" json:"paymentMethod,omitempty"`
+	StoreId       string                 `protobuf:"bytes,7,opt,name=storeId,proto3" json:"storeId,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -97,12 +99,33 @@ func (x *Order) GetProducts() []*Order_OrderProduct {
 	return nil
 }
 
+func (x *Order) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *Order) GetStoreId() string {
+	if x != nil {
+		return x.StoreId
+	}
+	return ""
+}
+
 type PostOrderRequest struct {
 	state         protoimpl.MessageState           `protogen:"open.v1"`
 	AccountId     string                           `protobuf:"bytes,2,opt,name=accountId,proto3" json:"accountId,omitempty"`
 	Products      []*PostOrderRequest_OrderProduct `protobuf:"bytes,4,rep,name=products,proto3" json:"products,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	PaymentMethod string                           `protobuf:"bytes,5,opt,name=paymentMethod,proto3" json:"paymentMethod,omitempty"`
+	// Dark store to pick the order from. When set, the ordered quantities
+	// are reserved from its stock and the order fails if any is short.
+	StoreId string `protobuf:"bytes,6,opt,name=storeId,proto3" json:"storeId,omitempty"`
+	// Identifies the checkout attempt: retrying with the same key returns
+	// the same order instead of charging or reserving stock again
+	IdempotencyKey string `protobuf:"bytes,7,opt,name=idempotencyKey,proto3" json:"idempotencyKey,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *PostOrderRequest) Reset() {
@@ -149,6 +172,27 @@ func (x *PostOrderRequest) GetProducts() []*PostOrderRequest_OrderProduct {
 	return nil
 }
 
+func (x *PostOrderRequest) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *PostOrderRequest) GetStoreId() string {
+	if x != nil {
+		return x.StoreId
+	}
+	return ""
+}
+
+func (x *PostOrderRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
 type PostOrderResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Order         *Order                 `protobuf:"bytes,1,opt,name=order,proto3" json:"order,omitempty"`
@@ -501,7 +545,7 @@ var File_order_proto protoreflect.FileDescriptor
 
 const file_order_proto_rawDesc = "" +
 	"\n" +
-	"\vorder.proto\x12\x02pb\"\xb0\x02\n" +
+	"\vorder.proto\x12\x02pb\"\xf0\x02\n" +
 	"\x05Order\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1c\n" +
 	"\tcreatedAt\x18\x02 \x01(\fR\tcreatedAt\x12\x1c\n" +
@@ -509,16 +553,21 @@ const file_order_proto_rawDesc = "" +
 	"\n" +
 	"totalPrice\x18\x04 \x01(\x01R\n" +
 	"totalPrice\x122\n" +
-	"\bproducts\x18\x05 \x03(\v2\x16.pb.Order.OrderProductR\bproducts\x1a\x86\x01\n" +
+	"\bproducts\x18\x05 \x03(\v2\x16.pb.Order.OrderProductR\bproducts\x12$\n" +
+	"\rpaymentMethod\x18\x06 \x01(\tR\rpaymentMethod\x12\x18\n" +
+	"\astoreId\x18\a \x01(\tR\astoreId\x1a\x86\x01\n" +
 	"\fOrderProduct\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
 	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x14\n" +
 	"\x05price\x18\x04 \x01(\x01R\x05price\x12\x1a\n" +
-	"\bquantity\x18\x05 \x01(\rR\bquantity\"\xb9\x01\n" +
+	"\bquantity\x18\x05 \x01(\rR\bquantity\"\xa1\x02\n" +
 	"\x10PostOrderRequest\x12\x1c\n" +
 	"\taccountId\x18\x02 \x01(\tR\taccountId\x12=\n" +
-	"\bproducts\x18\x04 \x03(\v2!.pb.PostOrderRequest.OrderProductR\bproducts\x1aH\n" +
+	"\bproducts\x18\x04 \x03(\v2!.pb.PostOrderRequest.OrderProductR\bproducts\x12$\n" +
+	"\rpaymentMethod\x18\x05 \x01(\tR\rpaymentMethod\x12\x18\n" +
+	"\astoreId\x18\x06 \x01(\tR\astoreId\x12&\n" +
+	"\x0eidempotencyKey\x18\a \x01(\tR\x0eidempotencyKey\x1aH\n" +
 	"\fOrderProduct\x12\x1c\n" +
 	"\tproductId\x18\x02 \x01(\tR\tproductId\x12\x1a\n" +
 	"\bquantity\x18\x03 \x01(\rR\bquantity\"4\n" +
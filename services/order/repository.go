@@ -3,15 +3,28 @@ package order
 import (
 	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 )
 
 type Repository interface {
 	Close() error
+	Ping(ctx context.Context) error
+	// PutOrder records a new order, or returns errOrderExists when one with
+	// the same id was recorded first
 	PutOrder(ctx context.Context, o Order) error
+	// GetOrder returns an order of any status, or ErrOrderNotFound
+	GetOrder(ctx context.Context, id string) (*Order, error)
+	SetOrderStatus(ctx context.Context, id, status string) error
+	// GetOrderforAccount only returns placed orders, newest first
 	GetOrderforAccount(ctx context.Context, accountId string) ([]Order, error)
+	// ListPendingOrders returns up to limit orders still pending that were
+	// created before the given time, oldest first
+	ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) ([]Order, error)
 }
 
 type postgresRepository struct {
@@ -34,103 +47,156 @@ func (r *postgresRepository) Close() error {
 	return r.db.Close()
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *postgresRepository) PutOrder(ctx context.Context, o Order) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "PutOrder")
+	defer func() { done(err) }()
 	txn, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if err != nil {
-			log.Println(err)
+			if !errors.Is(err, errOrderExists) {
+				slog.ErrorContext(ctx, "Error saving order", "order_id", o.ID, "err", err)
+			}
 			txn.Rollback()
 			return
 		}
 		err = txn.Commit()
 	}()
-	txn.ExecContext(ctx, "INSERT INTO orders(id,created_at,account_id,total_price) VALUES ($1,$2,$3,$4)", o.ID, o.CreatedAt, o.AccountId, o.TotalPrice)
+	res, err := txn.ExecContext(ctx, "INSERT INTO orders(id,created_at,account_id,total_price,payment_method,store_id,status) VALUES ($1,$2,$3,$4,$5,$6,$7) ON CONFLICT (id) DO NOTHING", o.ID, o.CreatedAt, o.AccountId, o.TotalPrice, o.PaymentMethod, o.StoreID, o.Status)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errOrderExists
 	}
 
-	stmt, _ := txn.PrepareContext(ctx, pq.CopyIn("order_products", "order_id", "product_id", "quantity"))
+	stmt, err := txn.PrepareContext(ctx, pq.CopyIn("order_products", "order_id", "product_id", "quantity"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 	for _, p := range o.Products {
-		_, err = stmt.ExecContext(ctx, o.ID, p.ID, p.Quantity)
-		if err != nil {
-			log.Println(err)
-			return
+		if _, err = stmt.ExecContext(ctx, o.ID, p.ID, p.Quantity); err != nil {
+			return err
 		}
-
 	}
 	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+func (r *postgresRepository) GetOrder(ctx context.Context, id string) (_ *Order, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetOrder")
+	defer func() { done(err) }()
+	o := &Order{}
+	err = r.db.QueryRowContext(ctx,
+		`SELECT id,created_at,account_id,total_price::money::numeric::float8,payment_method,store_id,status FROM orders WHERE id=$1`, id,
+	).Scan(&o.ID, &o.CreatedAt, &o.AccountId, &o.TotalPrice, &o.PaymentMethod, &o.StoreID, &o.Status)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT product_id,quantity FROM order_products WHERE order_id=$1", id)
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, err
 	}
-	stmt.Close()
-	return
+	defer rows.Close()
+	o.Products = []OrderedProduct{}
+	for rows.Next() {
+		p := OrderedProduct{}
+		if err := rows.Scan(&p.ID, &p.Quantity); err != nil {
+			return nil, err
+		}
+		o.Products = append(o.Products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (r *postgresRepository) SetOrderStatus(ctx context.Context, id, status string) (err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "SetOrderStatus")
+	defer func() { done(err) }()
+	_, err = r.db.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE id=$1", id, status)
+	return err
 }
 
-func (r *postgresRepository) GetOrderforAccount(ctx context.Context, accountId string) ([]Order, error) {
+func (r *postgresRepository) GetOrderforAccount(ctx context.Context, accountId string) (_ []Order, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "GetOrderforAccount")
+	defer func() { done(err) }()
 	rows, err := r.db.QueryContext(
 		ctx,
-		`SELECT o.id,o.created_at,o.account_id, o.total_price::money::numeric::float8,op.product_id,op.quantity FROM orders o JOIN order_products op ON(o.id=op.order_id) WHERE o.account_id=$1,ORDER BY o.id`, accountId,
+		`SELECT o.id,o.created_at,o.account_id, o.total_price::money::numeric::float8,o.payment_method,o.store_id,op.product_id,op.quantity FROM orders o JOIN order_products op ON(o.id=op.order_id) WHERE o.account_id=$1 AND o.status='placed' ORDER BY o.created_at DESC, o.id`, accountId,
 	)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Error querying orders", "account_id", accountId, "err", err)
+		return nil, err
 	}
 	defer rows.Close()
 
+	// Rows of one order are adjacent; collect its products as they come
 	orders := []Order{}
-	order := &Order{}
-	lastOrder := &Order{}
-	orderedProduct := &OrderedProduct{}
-	products := []OrderedProduct{}
-
 	for rows.Next() {
-		if err = rows.Scan(
-			&order.ID,
-			&order.CreatedAt,
-			&order.AccountId,
-			&order.TotalPrice,
-			&orderedProduct.ID,
-			&orderedProduct.Quantity,
-		); err != nil {
+		o := Order{Status: OrderPlaced}
+		p := OrderedProduct{}
+		if err = rows.Scan(&o.ID, &o.CreatedAt, &o.AccountId, &o.TotalPrice, &o.PaymentMethod, &o.StoreID, &p.ID, &p.Quantity); err != nil {
 			return nil, err
 		}
-
-		if lastOrder.ID != " " && lastOrder.ID != order.ID {
-			newOrder := Order{
-				ID:         lastOrder.ID,
-				AccountId:  lastOrder.AccountId,
-				CreatedAt:  lastOrder.CreatedAt,
-				TotalPrice: lastOrder.TotalPrice,
-				Products:   lastOrder.Products,
-			}
-			orders = append(orders, newOrder)
-			products = []OrderedProduct{}
-
+		if n := len(orders); n > 0 && orders[n-1].ID == o.ID {
+			orders[n-1].Products = append(orders[n-1].Products, p)
+			continue
 		}
-
-		products = append(products, OrderedProduct{
-			ID:       orderedProduct.ID,
-			Quantity: orderedProduct.Quantity,
-		})
-		*lastOrder = *order
+		o.Products = []OrderedProduct{p}
+		orders = append(orders, o)
 	}
-
-	newOrder := Order{
-		ID:         lastOrder.ID,
-		AccountId:  lastOrder.AccountId,
-		CreatedAt:  lastOrder.CreatedAt,
-		TotalPrice: lastOrder.TotalPrice,
-		Products:   lastOrder.Products,
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
-	orders = append(orders, newOrder)
+	return orders, nil
+}
 
+func (r *postgresRepository) ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) (_ []Order, err error) {
+	ctx, done := tracing.Query(ctx, "postgresql", "ListPendingOrders")
+	defer func() { done(err) }()
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id FROM orders WHERE status='pending' AND created_at < $1 ORDER BY created_at LIMIT $2",
+		createdBefore, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+
+	orders := []Order{}
+	for _, id := range ids {
+		o, err := r.GetOrder(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+	}
 	return orders, nil
 }
@@ -2,11 +2,18 @@ package order
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
-
+	"time"
+
+	"github.com/theshubhamy/microGo/pkg/auth"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/pkg/health"
+	"github.com/theshubhamy/microGo/pkg/lifecycle"
+	"github.com/theshubhamy/microGo/pkg/logging"
+	"github.com/theshubhamy/microGo/pkg/metrics"
+	"github.com/theshubhamy/microGo/pkg/tracing"
 	"github.com/theshubhamy/microGo/services/account"
 	"github.com/theshubhamy/microGo/services/catalog"
 	"github.com/theshubhamy/microGo/services/order/pb"
@@ -14,55 +21,70 @@ import (
 	"google.golang.org/grpc/reflection"
 )
 
+// roleRules protects every RPC: each acts for an account, which the handler
+// checks is the caller's own.
+var roleRules = map[string][]string{
+	pb.OrderService_PostOrder_FullMethodName:           {},
+	pb.OrderService_GetOrdersForAccount_FullMethodName: {},
+}
+
 type grpcServer struct {
 	pb.UnimplementedOrderServiceServer
 	service       Service
 	accountClient *account.Client
 	catalogClient *catalog.Client
+	accountPolicy account.VerificationPolicy
 }
 
-func ListenGrpcServer(s Service, accountURL, catalogURL string, port int) error {
-	accountClient, err := account.NewClient(accountURL)
-	if err != nil {
-		log.Fatal(err)
-		return err
-	}
-	catalogClient, err := catalog.NewClient(catalogURL)
-	if err != nil {
-		log.Fatal(err)
-		return err
-	}
+// ListenGrpcServer serves the order service until ctx is done, checking
+// access tokens with verify and reporting health from checks. Orders are
+// refused for accounts that don't satisfy accountPolicy. On shutdown it
+// keeps serving for drainDelay while reporting NOT_SERVING, then gives
+// in-flight RPCs up to shutdownTimeout to finish.
+func ListenGrpcServer(ctx context.Context, s Service, verify auth.Verifier, accountClient *account.Client, catalogClient *catalog.Client, accountPolicy account.VerificationPolicy, checks health.Checks, port int, drainDelay, shutdownTimeout time.Duration) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
-		log.Fatal(err)
-		accountClient.Close()
-		catalogClient.Close()
 		return err
 	}
 
-	server := grpc.NewServer()
-	pb.RegisterOrderServiceServer(server, &grpcServer{UnimplementedOrderServiceServer: pb.UnimplementedOrderServiceServer{}, service: s, accountClient: accountClient, catalogClient: catalogClient})
+	server := grpc.NewServer(
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			metrics.UnaryServerInterceptor(),
+			logging.UnaryServerInterceptor(),
+			errs.UnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(verify, "", roleRules),
+		),
+	)
+	pb.RegisterOrderServiceServer(server, &grpcServer{UnimplementedOrderServiceServer: pb.UnimplementedOrderServiceServer{}, service: s, accountClient: accountClient, catalogClient: catalogClient, accountPolicy: accountPolicy})
+	hs := health.Register(ctx, server, pb.OrderService_ServiceDesc.ServiceName, checks)
 
 	reflection.Register(server)
-	return server.Serve(lis)
+	return lifecycle.ServeGRPC(ctx, server, hs, lis, drainDelay, shutdownTimeout)
 }
 
 // PostOrder implements pb.OrderServiceServer.
 func (server *grpcServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
-	_, err := server.accountClient.GetAccount(ctx, r.AccountId)
+	if err := auth.RequireSubject(ctx, r.AccountId); err != nil {
+		return nil, err
+	}
+	acc, err := server.accountClient.GetAccount(ctx, r.AccountId)
 	if err != nil {
-		log.Println("Error getting account by id", err)
-		return nil, errors.New("account not found")
+		slog.ErrorContext(ctx, "Error getting account", "account_id", r.AccountId, "err", err)
+		return nil, err
+	}
+	if err := server.accountPolicy.Check(acc); err != nil {
+		return nil, err
 	}
 	productIds := []string{}
 	for _, p := range r.Products {
 		productIds = append(productIds, p.ProductId)
 	}
 
-	orderedProducts, err := server.catalogClient.GetProducts(ctx, "", productIds, 0, 0)
+	orderedProducts, err := server.catalogClient.GetProducts(ctx, "", "", productIds, 0, 0)
 	if err != nil {
-		log.Println("Error with find orderedProduct", err)
-		return nil, errors.New("product not found")
+		slog.ErrorContext(ctx, "Error getting ordered products", "err", err)
+		return nil, err
 	}
 	products := []OrderedProduct{}
 	for _, p := range *orderedProducts {
@@ -81,23 +103,30 @@ func (server *grpcServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest)
 		}
 
 		if product.Quantity != 0 {
+			if !p.Active {
+				return nil, ErrProductUnavailable
+			}
 			products = append(products, product)
 		}
 	}
 
 	// Call service implementation
-	order, err := server.service.PostOrder(ctx, r.AccountId, products)
+	order, err := server.service.PostOrder(ctx, r.AccountId, products, r.PaymentMethod, r.StoreId, r.IdempotencyKey)
 	if err != nil {
-		log.Println("Error posting order: ", err)
-		return nil, errors.New("could not post order")
+		slog.ErrorContext(ctx, "Error posting order", "account_id", r.AccountId, "err", err)
+		// Payment failures from the wallet carry their own status, anything
+		// else is reported as an internal error by the interceptor
+		return nil, err
 	}
 
 	// Make response order
 	orderProto := &pb.Order{
-		Id:         order.ID,
-		AccountId:  order.AccountId,
-		TotalPrice: order.TotalPrice,
-		Products:   []*pb.Order_OrderProduct{},
+		Id:            order.ID,
+		AccountId:     order.AccountId,
+		TotalPrice:    order.TotalPrice,
+		PaymentMethod: order.PaymentMethod,
+		StoreId:       order.StoreID,
+		Products:      []*pb.Order_OrderProduct{},
 	}
 	orderProto.CreatedAt, _ = order.CreatedAt.MarshalBinary()
 	for _, p := range order.Products {
@@ -115,9 +144,12 @@ func (server *grpcServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest)
 }
 
 func (s *grpcServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
+	if err := auth.RequireSubject(ctx, r.AccountId, auth.RoleAdmin, auth.RoleSupport); err != nil {
+		return nil, err
+	}
 	accountOrders, err := s.service.GetOrdersForAccount(ctx, r.AccountId)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Error getting orders for account", "account_id", r.AccountId, "err", err)
 		return nil, err
 	}
 
@@ -131,19 +163,21 @@ func (s *grpcServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersFor
 	for id := range productIDMap {
 		productIDs = append(productIDs, id)
 	}
-	products, err := s.catalogClient.GetProducts(ctx, "", productIDs, 0, 0)
+	products, err := s.catalogClient.GetProducts(ctx, "", "", productIDs, 0, 0)
 	if err != nil {
-		log.Println("Error getting account products: ", err)
+		slog.ErrorContext(ctx, "Error getting products of account orders", "account_id", r.AccountId, "err", err)
 		return nil, err
 	}
 
 	orders := []*pb.Order{}
 	for _, o := range accountOrders {
 		op := &pb.Order{
-			AccountId:  o.AccountId,
-			Id:         o.ID,
-			TotalPrice: o.TotalPrice,
-			Products:   []*pb.Order_OrderProduct{},
+			AccountId:     o.AccountId,
+			Id:            o.ID,
+			TotalPrice:    o.TotalPrice,
+			PaymentMethod: o.PaymentMethod,
+			StoreId:       o.StoreID,
+			Products:      []*pb.Order_OrderProduct{},
 		}
 		op.CreatedAt, _ = o.CreatedAt.MarshalBinary()
 
@@ -2,21 +2,78 @@ package order
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"math"
 	"time"
 
 	"github.com/segmentio/ksuid"
+	"github.com/theshubhamy/microGo/pkg/errs"
+	"github.com/theshubhamy/microGo/services/account"
+	"github.com/theshubhamy/microGo/services/catalog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	PaymentCashOnDelivery = "cod"
+	PaymentWallet         = "wallet"
+)
+
+// Orders are recorded as pending before stock is reserved and the wallet
+// charged, and only become placed once both went through.
+const (
+	OrderPending = "pending"
+	OrderPlaced  = "placed"
+	OrderFailed  = "failed"
+)
+
+var (
+	ErrInvalidPaymentMethod   = errs.InvalidArgument("payment_method", "invalid payment method")
+	ErrProductUnavailable     = errs.FailedPrecondition("product is not available")
+	ErrOrderNotFound          = errs.NotFound("order not found")
+	ErrIdempotencyKeyRequired = errs.InvalidArgument("idempotencyKey", "idempotency key is required")
+	ErrIdempotencyConflict    = errs.Conflict("idempotency key was already used for a different order")
+	ErrOrderFailed            = errs.FailedPrecondition("an order with this idempotency key already failed, retry with a new key")
+	ErrStoreRequired          = errs.InvalidArgument("storeId", "store id is required")
+
+	// errOrderExists tells PostOrder that a concurrent request recorded the
+	// order first
+	errOrderExists = errors.New("order already exists")
+)
+
+// Wallet is the part of the account service that checkout pays through.
+type Wallet interface {
+	DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*account.WalletTransaction, int64, error)
+}
+
+// Stock is the part of the catalog service that checkout reserves stock
+// through.
+type Stock interface {
+	ReserveStock(ctx context.Context, storeID, orderID string, items []catalog.StockDelta) ([]catalog.Stock, error)
+	ReleaseStock(ctx context.Context, storeID, orderID string) ([]catalog.Stock, error)
+}
+
 type Service interface {
-	PostOrder(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error)
+	// PostOrder reserves the ordered quantities at storeID, or the default
+	// store when it is empty. Retrying with the same idempotency key returns the same order without
+	// reserving or charging again.
+	PostOrder(ctx context.Context, accountId string, products []OrderedProduct, paymentMethod string, storeID string, idempotencyKey string) (*Order, error)
 	GetOrdersForAccount(ctx context.Context, accountId string) ([]Order, error)
+	// ResumePendingOrders completes orders pending for longer than
+	// olderThan and returns how many ended up placed or failed
+	ResumePendingOrders(ctx context.Context, olderThan time.Duration) (int, error)
 }
 type Order struct {
-	ID         string
-	CreatedAt  time.Time
-	TotalPrice float64
-	AccountId  string
-	Products   []OrderedProduct
+	ID            string
+	CreatedAt     time.Time
+	TotalPrice    float64
+	AccountId     string
+	PaymentMethod string
+	StoreID       string
+	Status        string
+	Products      []OrderedProduct
 }
 
 type OrderedProduct struct {
@@ -28,19 +85,52 @@ type OrderedProduct struct {
 }
 
 type orderService struct {
-	repository Repository
+	repository     Repository
+	wallet         Wallet
+	stock          Stock
+	defaultStoreID string
+}
+
+// NewService returns the order service. Orders that don't name a store are
+// fulfilled from defaultStoreID, or rejected when it is empty.
+func NewService(r Repository, wallet Wallet, stock Stock, defaultStoreID string) Service {
+	return &orderService{r, wallet, stock, defaultStoreID}
 }
 
-func NewService(r Repository) Service {
-	return &orderService{r}
+// orderID derives the order id from the client's idempotency key. The
+// stock reservation and wallet debit are keyed on the order id, so a retried
+// checkout lands on the same order, reservation and debit.
+func orderID(accountID, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(accountID + "\x00" + idempotencyKey))
+	id, _ := ksuid.FromBytes(sum[:20])
+	return id.String()
 }
 
-func (os orderService) PostOrder(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error) {
+func (os orderService) PostOrder(ctx context.Context, accountId string, products []OrderedProduct, paymentMethod string, storeID string, idempotencyKey string) (*Order, error) {
+	if paymentMethod == "" {
+		paymentMethod = PaymentCashOnDelivery
+	}
+	if paymentMethod != PaymentCashOnDelivery && paymentMethod != PaymentWallet {
+		return nil, ErrInvalidPaymentMethod
+	}
+	if idempotencyKey == "" {
+		return nil, ErrIdempotencyKeyRequired
+	}
+	if storeID == "" {
+		storeID = os.defaultStoreID
+	}
+	if storeID == "" {
+		return nil, ErrStoreRequired
+	}
+
 	order := &Order{
-		ID:        ksuid.New().String(),
-		CreatedAt: time.Now().UTC(),
-		AccountId: accountId,
-		Products:  products,
+		ID:            orderID(accountId, idempotencyKey),
+		CreatedAt:     time.Now().UTC(),
+		AccountId:     accountId,
+		PaymentMethod: paymentMethod,
+		StoreID:       storeID,
+		Status:        OrderPending,
+		Products:      products,
 	}
 
 	order.TotalPrice = 0.0
@@ -48,13 +138,137 @@ func (os orderService) PostOrder(ctx context.Context, accountId string, products
 	for _, p := range products {
 		order.TotalPrice += p.Price * float64(p.Quantity)
 	}
-	err := os.repository.PutOrder(ctx, *order)
+
+	existing, err := os.repository.GetOrder(ctx, order.ID)
+	if errors.Is(err, ErrOrderNotFound) {
+		err = os.repository.PutOrder(ctx, *order)
+		if err == nil {
+			return os.completeOrder(ctx, order)
+		}
+		if errors.Is(err, errOrderExists) {
+			// A concurrent request with the same key recorded it first
+			existing, err = os.repository.GetOrder(ctx, order.ID)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if !existing.sameRequest(order) {
+		return nil, ErrIdempotencyConflict
+	}
+	switch existing.Status {
+	case OrderPlaced:
+		existing.Products = products
+		return existing, nil
+	case OrderFailed:
+		return nil, ErrOrderFailed
+	}
+	// A pending order is finished with the amounts first recorded
+	order.CreatedAt, order.TotalPrice = existing.CreatedAt, existing.TotalPrice
+	return os.completeOrder(ctx, order)
+}
+
+// completeOrder reserves stock and charges the wallet for a pending order.
+// Stock is reserved before payment so a sold-out item never costs the
+// customer a refund. Errors that retrying can't fix fail the order and
+// release its stock. Other errors, such as a timeout, leave it pending so a
+// retry with the same key picks up where this attempt stopped.
+func (os orderService) completeOrder(ctx context.Context, order *Order) (*Order, error) {
+	items := []catalog.StockDelta{}
+	for _, p := range order.Products {
+		items = append(items, catalog.StockDelta{ProductID: p.ID, Delta: int32(p.Quantity)})
+	}
+	if _, err := os.stock.ReserveStock(ctx, order.StoreID, order.ID, items); err != nil {
+		return nil, os.failOrder(ctx, order, false, err)
+	}
+
+	amount := int64(math.Round(order.TotalPrice * 100))
+	if order.PaymentMethod == PaymentWallet {
+		if _, _, err := os.wallet.DebitWallet(ctx, order.AccountId, amount, "order:"+order.ID, "order:"+order.ID); err != nil {
+			return nil, os.failOrder(ctx, order, true, err)
+		}
+	}
+
+	if err := os.repository.SetOrderStatus(ctx, order.ID, OrderPlaced); err != nil {
+		return nil, err
+	}
+	order.Status = OrderPlaced
 	return order, nil
 }
 
+// failOrder marks the order failed when err is final and then releases its
+// stock if it was reserved. It returns err.
+func (os orderService) failOrder(ctx context.Context, order *Order, reserved bool, err error) error {
+	if !final(err) {
+		return err
+	}
+	if setErr := os.repository.SetOrderStatus(ctx, order.ID, OrderFailed); setErr != nil {
+		// Still pending: a retry finishes or fails it, so keep the stock
+		slog.ErrorContext(ctx, "Error failing order", "order_id", order.ID, "err", setErr)
+		return err
+	}
+	order.Status = OrderFailed
+	if reserved {
+		if _, releaseErr := os.stock.ReleaseStock(ctx, order.StoreID, order.ID); releaseErr != nil {
+			slog.ErrorContext(ctx, "Error releasing stock for order", "order_id", order.ID, "err", releaseErr)
+		}
+	}
+	return err
+}
+
+// final reports whether err is a refusal that retrying won't change, such as
+// insufficient stock or funds, rather than an outage or timeout.
+func final(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return true
+	}
+	return false
+}
+
+// sameRequest reports whether a retried order asks for what o recorded.
+func (o *Order) sameRequest(retry *Order) bool {
+	if o.AccountId != retry.AccountId || o.PaymentMethod != retry.PaymentMethod ||
+		o.StoreID != retry.StoreID || len(o.Products) != len(retry.Products) {
+		return false
+	}
+	quantities := map[string]uint32{}
+	for _, p := range o.Products {
+		quantities[p.ID] = p.Quantity
+	}
+	for _, p := range retry.Products {
+		if q, ok := quantities[p.ID]; !ok || q != p.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
 func (os orderService) GetOrdersForAccount(ctx context.Context, accountId string) ([]Order, error) {
 	return os.repository.GetOrderforAccount(ctx, accountId)
 }
+
+// ResumePendingOrders finishes orders whose checkout stopped half way, as a
+// retry with the same key would, since the client may never send one.
+// Reservation and debit are keyed by order, so an order whose stock or
+// payment already went through isn't reserved or charged twice. Orders that
+// still hit an outage stay pending for the next run.
+func (os orderService) ResumePendingOrders(ctx context.Context, olderThan time.Duration) (int, error) {
+	orders, err := os.repository.ListPendingOrders(ctx, time.Now().UTC().Add(-olderThan), 100)
+	if err != nil {
+		return 0, err
+	}
+	settled := 0
+	for i := range orders {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := os.completeOrder(ctx, &orders[i]); err != nil && !final(err) {
+			slog.WarnContext(ctx, "Error resuming pending order", "order_id", orders[i].ID, "err", err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
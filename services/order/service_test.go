@@ -0,0 +1,342 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theshubhamy/microGo/services/account"
+	"github.com/theshubhamy/microGo/services/catalog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeRepository struct {
+	Repository
+	orders map[string]*Order
+	// hidden orders aren't found by the next GetOrder, as if another request
+	// recorded them in between
+	hidden map[string]bool
+}
+
+func (r *fakeRepository) PutOrder(ctx context.Context, o Order) error {
+	if _, ok := r.orders[o.ID]; ok {
+		return errOrderExists
+	}
+	r.orders[o.ID] = &o
+	return nil
+}
+
+func (r *fakeRepository) GetOrder(ctx context.Context, id string) (*Order, error) {
+	o, ok := r.orders[id]
+	if r.hidden[id] {
+		delete(r.hidden, id)
+		ok = false
+	}
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	stored := *o
+	return &stored, nil
+}
+
+func (r *fakeRepository) SetOrderStatus(ctx context.Context, id, status string) error {
+	r.orders[id].Status = status
+	return nil
+}
+
+func (r *fakeRepository) ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) ([]Order, error) {
+	orders := []Order{}
+	for _, o := range r.orders {
+		if o.Status == OrderPending && o.CreatedAt.Before(createdBefore) {
+			orders = append(orders, *o)
+		}
+	}
+	return orders, nil
+}
+
+type fakeWallet struct {
+	err    error
+	debits []int64
+	keys   []string
+}
+
+func (w *fakeWallet) DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*account.WalletTransaction, int64, error) {
+	if w.err != nil {
+		return nil, 0, w.err
+	}
+	w.debits = append(w.debits, amount)
+	w.keys = append(w.keys, idempotencyKey)
+	return &account.WalletTransaction{Amount: amount, IdempotencyKey: idempotencyKey}, 0, nil
+}
+
+type fakeStock struct {
+	err      error
+	reserved []string
+	released []string
+}
+
+func (s *fakeStock) ReserveStock(ctx context.Context, storeID, orderID string, items []catalog.StockDelta) ([]catalog.Stock, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.reserved = append(s.reserved, storeID+"/"+orderID)
+	return []catalog.Stock{}, nil
+}
+
+func (s *fakeStock) ReleaseStock(ctx context.Context, storeID, orderID string) ([]catalog.Stock, error) {
+	s.released = append(s.released, storeID+"/"+orderID)
+	return []catalog.Stock{}, nil
+}
+
+var testProducts = []OrderedProduct{
+	{ID: "p1", Price: 12.5, Quantity: 2},
+	{ID: "p2", Price: 0.99, Quantity: 1},
+}
+
+func TestPostOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultStore  string
+		store         string
+		paymentMethod string
+		key           string
+		stockErr      error
+		walletErr     error
+		wantErr       error
+		wantCode      codes.Code
+		wantStatus    string
+		wantReserved  int
+		wantReleased  int
+		wantDebits    []int64
+	}{
+		{
+			name: "cash on delivery", store: "s1", key: "k1",
+			wantStatus: OrderPlaced, wantReserved: 1,
+		},
+		{
+			name: "wallet is charged in paise", store: "s1", paymentMethod: PaymentWallet, key: "k1",
+			wantStatus: OrderPlaced, wantReserved: 1, wantDebits: []int64{2599},
+		},
+		{
+			name: "default store", defaultStore: "s9", key: "k1",
+			wantStatus: OrderPlaced, wantReserved: 1,
+		},
+		{
+			name: "no store", key: "k1",
+			wantErr: ErrStoreRequired,
+		},
+		{
+			name: "no idempotency key", store: "s1",
+			wantErr: ErrIdempotencyKeyRequired,
+		},
+		{
+			name: "unknown payment method", store: "s1", paymentMethod: "card", key: "k1",
+			wantErr: ErrInvalidPaymentMethod,
+		},
+		{
+			name: "out of stock fails without release", store: "s1", key: "k1",
+			stockErr: status.Error(codes.FailedPrecondition, "insufficient stock"),
+			wantCode: codes.FailedPrecondition, wantStatus: OrderFailed,
+		},
+		{
+			name: "stock timeout stays pending", store: "s1", key: "k1",
+			stockErr: status.Error(codes.DeadlineExceeded, "timeout"),
+			wantCode: codes.DeadlineExceeded, wantStatus: OrderPending,
+		},
+		{
+			name: "declined payment releases stock", store: "s1", paymentMethod: PaymentWallet, key: "k1",
+			walletErr: status.Error(codes.FailedPrecondition, "insufficient wallet balance"),
+			wantCode:  codes.FailedPrecondition, wantStatus: OrderFailed, wantReserved: 1, wantReleased: 1,
+		},
+		{
+			name: "payment outage keeps stock", store: "s1", paymentMethod: PaymentWallet, key: "k1",
+			walletErr: status.Error(codes.Unavailable, "unavailable"),
+			wantCode:  codes.Unavailable, wantStatus: OrderPending, wantReserved: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRepository{orders: map[string]*Order{}}
+			wallet := &fakeWallet{err: tt.walletErr}
+			stock := &fakeStock{err: tt.stockErr}
+			s := NewService(r, wallet, stock, tt.defaultStore)
+
+			o, err := s.PostOrder(context.Background(), "acc", testProducts, tt.paymentMethod, tt.store, tt.key)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantCode != codes.OK:
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("err = %v, want code %v", err, tt.wantCode)
+				}
+			case err != nil:
+				t.Fatal(err)
+			default:
+				if o.Status != OrderPlaced {
+					t.Errorf("returned status %q", o.Status)
+				}
+			}
+
+			id := orderID("acc", tt.key)
+			if tt.wantStatus == "" {
+				if len(r.orders) != 0 {
+					t.Errorf("recorded %d orders for a rejected request", len(r.orders))
+				}
+			} else if got := r.orders[id]; got == nil || got.Status != tt.wantStatus {
+				t.Errorf("stored order = %+v, want status %q", got, tt.wantStatus)
+			}
+			if len(stock.reserved) != tt.wantReserved || len(stock.released) != tt.wantReleased {
+				t.Errorf("reserved %v and released %v", stock.reserved, stock.released)
+			}
+			if len(wallet.debits) != len(tt.wantDebits) {
+				t.Fatalf("debits = %v, want %v", wallet.debits, tt.wantDebits)
+			}
+			for i, amount := range tt.wantDebits {
+				if wallet.debits[i] != amount || wallet.keys[i] != "order:"+id {
+					t.Errorf("debit %d = %d with key %q, want %d with key order:%s", i, wallet.debits[i], wallet.keys[i], amount, id)
+				}
+			}
+		})
+	}
+}
+
+func TestPostOrderRetry(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRepository{orders: map[string]*Order{}}
+	wallet := &fakeWallet{}
+	stock := &fakeStock{}
+	s := NewService(r, wallet, stock, "s1")
+
+	// A timeout leaves the order pending; the retry finishes it
+	wallet.err = status.Error(codes.DeadlineExceeded, "timeout")
+	if _, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k1"); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("first attempt: err = %v", err)
+	}
+	wallet.err = nil
+	first, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != OrderPlaced {
+		t.Fatalf("status = %q after retry", first.Status)
+	}
+
+	// Once placed, retries return the order without charging again
+	again, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || len(wallet.debits) != 1 {
+		t.Errorf("retry returned %s with %d debits, want %s with 1", again.ID, len(wallet.debits), first.ID)
+	}
+	// Reservations are keyed by order, so repeating one is harmless
+	for _, key := range stock.reserved {
+		if key != "s1/"+first.ID {
+			t.Errorf("reserved %s, want s1/%s", key, first.ID)
+		}
+	}
+
+	// The same key for a different basket is refused
+	changed := []OrderedProduct{{ID: "p1", Price: 12.5, Quantity: 3}}
+	if _, err := s.PostOrder(ctx, "acc", changed, PaymentWallet, "", "k1"); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Errorf("changed basket: err = %v, want %v", err, ErrIdempotencyConflict)
+	}
+
+	// A failed order stays failed
+	stock.err = status.Error(codes.FailedPrecondition, "insufficient stock")
+	if _, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k2"); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("out of stock: err = %v", err)
+	}
+	stock.err = nil
+	if _, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k2"); !errors.Is(err, ErrOrderFailed) {
+		t.Errorf("retrying a failed order: err = %v, want %v", err, ErrOrderFailed)
+	}
+}
+
+func TestPostOrderConcurrentRetry(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRepository{orders: map[string]*Order{}}
+	wallet := &fakeWallet{}
+	stock := &fakeStock{}
+	s := NewService(r, wallet, stock, "s1")
+
+	// The first request is still in flight when the second one looks it up
+	wallet.err = status.Error(codes.DeadlineExceeded, "timeout")
+	if _, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k1"); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("first request: err = %v", err)
+	}
+	wallet.err = nil
+	id := orderID("acc", "k1")
+	r.hidden = map[string]bool{id: true}
+
+	o, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", "k1")
+	if err != nil {
+		t.Fatalf("second request: err = %v", err)
+	}
+	if o.ID != id || o.Status != OrderPlaced || len(wallet.debits) != 1 {
+		t.Errorf("second request placed %s as %q with %d debits", o.ID, o.Status, len(wallet.debits))
+	}
+}
+
+func TestResumePendingOrders(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRepository{orders: map[string]*Order{}}
+	wallet := &fakeWallet{}
+	stock := &fakeStock{}
+	s := NewService(r, wallet, stock, "s1")
+
+	// Both checkouts time out and the clients never retry
+	wallet.err = status.Error(codes.DeadlineExceeded, "timeout")
+	for _, key := range []string{"k1", "k2"} {
+		if _, err := s.PostOrder(ctx, "acc", testProducts, PaymentWallet, "", key); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("checkout %s: err = %v", key, err)
+		}
+	}
+	placed, failed := orderID("acc", "k1"), orderID("acc", "k2")
+	for _, o := range r.orders {
+		o.CreatedAt = o.CreatedAt.Add(-time.Hour)
+	}
+
+	// Too recent to resume, the client may still retry
+	if n, err := s.ResumePendingOrders(ctx, 2*time.Hour); err != nil || n != 0 {
+		t.Fatalf("resumed %d recent orders, err = %v", n, err)
+	}
+
+	// Still unavailable, so the order stays pending for the next run
+	if n, err := s.ResumePendingOrders(ctx, time.Minute); err != nil || n != 0 {
+		t.Fatalf("resumed %d orders during an outage, err = %v", n, err)
+	}
+	if r.orders[placed].Status != OrderPending || len(stock.released) != 0 {
+		t.Fatalf("order %q with %v released during an outage", r.orders[placed].Status, stock.released)
+	}
+
+	wallet.err = nil
+	s = NewService(r, &declinedWallet{fakeWallet: wallet, declined: "order:" + failed}, stock, "s1")
+	n, err := s.ResumePendingOrders(ctx, time.Minute)
+	if err != nil || n != 2 {
+		t.Fatalf("resumed %d orders, err = %v", n, err)
+	}
+	if r.orders[placed].Status != OrderPlaced || len(wallet.debits) != 1 {
+		t.Errorf("resumed order is %q with %d debits", r.orders[placed].Status, len(wallet.debits))
+	}
+	if r.orders[failed].Status != OrderFailed || len(stock.released) != 1 || stock.released[0] != "s1/"+failed {
+		t.Errorf("declined order is %q, released %v", r.orders[failed].Status, stock.released)
+	}
+}
+
+// declinedWallet refuses the debit with one idempotency key.
+type declinedWallet struct {
+	*fakeWallet
+	declined string
+}
+
+func (w *declinedWallet) DebitWallet(ctx context.Context, accountID string, amount int64, idempotencyKey, reference string) (*account.WalletTransaction, int64, error) {
+	if idempotencyKey == w.declined {
+		return nil, 0, status.Error(codes.FailedPrecondition, "insufficient wallet balance")
+	}
+	return w.fakeWallet.DebitWallet(ctx, accountID, amount, idempotencyKey, reference)
+}